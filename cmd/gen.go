@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"api-gin/config"
+	"api-gin/infra/gen"
+	"api-gin/repo"
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+var genCmd = &cobra.Command{
+	Use:   "gen",
+	Short: "code generation tools",
+	Long:  `code generation tools`,
+}
+
+var genModelCmd = &cobra.Command{
+	Use:   "model",
+	Short: "generate models from mysql schema",
+	Long:  `generate models from mysql schema, optionally with repo skeletons and wire providers`,
+	Run:   genModelCmdExculpate,
+}
+
+var genModuleCmd = &cobra.Command{
+	Use:   "module <name>",
+	Short: "generate controller/handler/repo/route for a new module",
+	Long:  `generate controller, handler, request/response types, repo and tests for a new module, then register them in wire sets and router`,
+	Args:  cobra.ExactArgs(1),
+	Run:   genModuleCmdExculpate,
+}
+
+var (
+	genTables   []string // 需要生成的表
+	genModelDir string   // model输出目录
+	genRepo     bool     // 同时生成repo
+	genRepoDir  string   // repo输出目录
+	genWireFile string   // repo加入的wire集合文件
+	genForce    bool     // 覆盖已存在的文件
+)
+
+func init() {
+	genModelCmd.Flags().StringSliceVarP(&genTables, "tables", "t", nil, "表名，多个以逗号分隔")
+	genModelCmd.Flags().StringVar(&genModelDir, "out", "./repo/model", "model输出目录")
+	genModelCmd.Flags().BoolVar(&genRepo, "repo", false, "同时生成repo骨架并加入wire的repoSet")
+	genModelCmd.Flags().StringVar(&genRepoDir, "repo-out", "./repo", "repo输出目录")
+	genModelCmd.Flags().StringVar(&genWireFile, "wire", "./server/wire_set.go", "wire集合文件，为空时不修改")
+	genCmd.PersistentFlags().BoolVarP(&genForce, "force", "f", false, "覆盖已存在的文件")
+	_ = genModelCmd.MarkFlagRequired("tables")
+	genCmd.AddCommand(genModelCmd, genModuleCmd)
+}
+
+func genModelCmdExculpate(cmd *cobra.Command, args []string) {
+	c, err := config.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := repo.NewDB(config.GetMySQLConfig(c))
+	if err != nil {
+		log.Fatal(err)
+	}
+	tables, err := gen.LoadTables(context.Background(), db, genTables)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	module, _, err := gen.ModulePath(".")
+	if err != nil {
+		log.Fatal(err)
+	}
+	modelImport, err := gen.ImportPath(genModelDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	o := gen.ModelOptions{
+		Package:     filepath.Base(modelImport),
+		ModelImport: modelImport,
+		NullImport:  module + "/infra/model",
+		LogImport:   module + "/infra/log",
+	}
+
+	providers := make([]string, 0, len(tables))
+	for _, t := range tables {
+		src, err := gen.Model(t, o)
+		if err != nil {
+			log.Fatal(err)
+		}
+		writeGenFile(filepath.Join(genModelDir, gen.ModelFile(t)), src)
+		if !genRepo {
+			continue
+		}
+		src, err = gen.Repo(t, o)
+		if err != nil {
+			log.Fatal(err)
+		}
+		writeGenFile(filepath.Join(genRepoDir, gen.RepoFile(t)), src)
+		providers = append(providers, gen.RepoProvider(t))
+	}
+
+	if len(providers) > 0 && genWireFile != "" {
+		editGenFile(genWireFile, func(src []byte) ([]byte, error) {
+			return gen.AddProviders(src, "repoSet", providers...)
+		})
+		fmt.Println("修改wire集合后，需要重新运行 wire gen ./server")
+	}
+}
+
+func genModuleCmdExculpate(cmd *cobra.Command, args []string) {
+	module, root, err := gen.ModulePath(".")
+	if err != nil {
+		log.Fatal(err)
+	}
+	m, err := gen.NewModule(module, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	files, err := m.Files()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for path, src := range files {
+		writeGenFile(filepath.Join(root, path), src)
+	}
+	editGenFile(filepath.Join(root, "server", "wire_set.go"), m.Register)
+	editGenFile(filepath.Join(root, "server", "engine.go"), m.Route)
+	fmt.Println("修改wire集合后，需要重新运行 wire gen ./server")
+}
+
+// writeGenFile 写入生成的文件，已存在且未指定--force时跳过
+func writeGenFile(path string, src []byte) {
+	if _, err := os.Stat(path); err == nil && !genForce {
+		fmt.Println("skip  ", path, "(已存在，使用--force覆盖)")
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(path, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("create", path)
+}
+
+// editGenFile 修改已有的文件
+func editGenFile(path string, fn func(src []byte) ([]byte, error)) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out, err := fn(src)
+	if err != nil {
+		log.Fatalf("修改%s失败：%v", path, err)
+	}
+	if string(out) == string(src) {
+		return
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("update", path)
+}
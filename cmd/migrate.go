@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"api-gin/config"
+	"api-gin/migrations"
+	"api-gin/repo"
+	"api-gin/repo/migrate"
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "database migration tools",
+	Long:  `database migration tools`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "apply pending migrations",
+	Long:  `apply pending migrations`,
+	Run:   migrateUpCmdExculpate,
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "rollback applied migrations",
+	Long:  `rollback applied migrations`,
+	Run:   migrateDownCmdExculpate,
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show migration status",
+	Long:  `show migration status`,
+	Run:   migrateStatusCmdExculpate,
+}
+
+var migrateCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "create a new migration",
+	Long:  `create a new migration`,
+	Args:  cobra.ExactArgs(1),
+	Run:   migrateCreateCmdExculpate,
+}
+
+var (
+	migrateDir       string // 迁移文件目录，为空时使用内置的迁移
+	migrateUpSteps   int    // 最多执行的个数
+	migrateDownSteps int    // 回滚的个数
+)
+
+func init() {
+	migrateCmd.PersistentFlags().StringVar(&migrateDir, "dir", "", "迁移文件目录，默认使用编译时内置的migrations")
+	migrateUpCmd.Flags().IntVarP(&migrateUpSteps, "steps", "n", 0, "最多执行N个迁移，0为全部")
+	migrateDownCmd.Flags().IntVarP(&migrateDownSteps, "steps", "n", 1, "回滚N个迁移")
+	migrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd, migrateCreateCmd)
+}
+
+func newMigrator() *migrate.Migrator {
+	c, err := config.NewConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := repo.NewDB(config.GetMySQLConfig(c))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var fsys fs.FS = migrations.FS
+	if migrateDir != "" {
+		fsys = os.DirFS(migrateDir)
+	}
+	m, err := migrate.New(db, fsys)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return m
+}
+
+func migrateUpCmdExculpate(cmd *cobra.Command, args []string) {
+	done, err := newMigrator().Up(context.Background(), migrateUpSteps)
+	for _, m := range done {
+		fmt.Printf("up   %d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(done) == 0 {
+		fmt.Println("没有需要执行的迁移")
+	}
+}
+
+func migrateDownCmdExculpate(cmd *cobra.Command, args []string) {
+	done, err := newMigrator().Down(context.Background(), migrateDownSteps)
+	for _, m := range done {
+		fmt.Printf("down %d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(done) == 0 {
+		fmt.Println("没有可以回滚的迁移")
+	}
+}
+
+func migrateStatusCmdExculpate(cmd *cobra.Command, args []string) {
+	status, err := newMigrator().Status(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range status {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Printf("%-20s %d_%s\n", applied, s.Version, s.Name)
+	}
+}
+
+func migrateCreateCmdExculpate(cmd *cobra.Command, args []string) {
+	dir := migrateDir
+	if dir == "" {
+		dir = "./migrations"
+	}
+	up, down, err := migrate.Create(dir, args[0], time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(up)
+	fmt.Println(down)
+}
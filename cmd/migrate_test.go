@@ -0,0 +1,17 @@
+package cmd
+
+import "testing"
+
+func TestMigrateSteps(t *testing.T) {
+	// up默认执行全部，down默认回滚一个，互不影响
+	if migrateUpSteps != 0 || migrateDownSteps != 1 {
+		t.Errorf("unexpected default steps: up %d, down %d", migrateUpSteps, migrateDownSteps)
+	}
+	if err := migrateDownCmd.ParseFlags([]string{"-n", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = migrateDownCmd.Flags().Set("steps", "1") })
+	if migrateUpSteps != 0 || migrateDownSteps != 3 {
+		t.Errorf("unexpected steps: up %d, down %d", migrateUpSteps, migrateDownSteps)
+	}
+}
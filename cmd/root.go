@@ -14,7 +14,7 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	// 添加其它cmd
-	rootCmd.AddCommand(startCmd, stoptCmd)
+	rootCmd.AddCommand(startCmd, stoptCmd, shardCmd, migrateCmd, genCmd)
 }
 func rootCmdExcutefunc(cmd *cobra.Command, args []string) {
 	fmt.Println("Welcom to OpenAPI.")
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"api-gin/repo"
+	"api-gin/server"
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"log"
+)
+
+var shardCmd = &cobra.Command{
+	Use:   "shard",
+	Short: "shard tables tools",
+	Long:  `shard tables tools`,
+}
+
+var shardPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "drop or archive expired shard tables",
+	Long:  `drop or archive expired shard tables`,
+	Run:   shardPruneCmdExculpate,
+}
+
+var pruneOptions repo.PruneOptions
+
+func init() {
+	shardPruneCmd.Flags().BoolVar(&pruneOptions.DryRun, "dry-run", false, "只打印将要执行的SQL")
+	shardPruneCmd.Flags().StringVar(&pruneOptions.ArchiveSchema, "archive", "", "归档库名，不为空时移动到该库而不是删除")
+	shardCmd.AddCommand(shardPruneCmd)
+}
+
+func shardPruneCmdExculpate(cmd *cobra.Command, args []string) {
+	// 初始化所有repo，分表repo会自动登记
+	_, cleanup, err := server.Initialize()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer cleanup()
+	ctx := context.Background()
+	for _, b := range repo.ShardRepos() {
+		if b.Retention <= 0 {
+			continue
+		}
+		sqls, err := b.PruneShards(ctx, pruneOptions)
+		for _, sql := range sqls {
+			if pruneOptions.DryRun {
+				fmt.Println("[dry-run]", sql)
+			} else {
+				fmt.Println(sql)
+			}
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
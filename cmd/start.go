@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"api-gin/repo"
 	"api-gin/server"
 	"context"
 	"errors"
@@ -21,11 +22,22 @@ var startCmd = &cobra.Command{
 	Run:   startCmdExculpate,
 }
 
+var preCreate int // 启动时预建分表的周期数
+
+func init() {
+	startCmd.Flags().IntVar(&preCreate, "pre-create", 0, "启动时预建之后N个周期的分表")
+}
+
 func startCmdExculpate(cmd *cobra.Command, args []string) {
-	app, err := server.Initialize()
+	app, cleanup, err := server.Initialize()
 	if err != nil {
 		log.Fatal(err)
 	}
+	if preCreate > 0 {
+		if err := repo.PreCreateShardTables(context.Background(), preCreate); err != nil {
+			log.Fatal(err)
+		}
+	}
 	addr := fmt.Sprintf("%s:%d", app.Host, app.Port)
 	fmt.Printf("点击访问: https://%s\n", addr)
 	srv := &http.Server{
@@ -38,6 +50,14 @@ func startCmdExculpate(cmd *cobra.Command, args []string) {
 			log.Fatalf("listen error: %s\n", err)
 		}
 	}()
+	// 管理端口，如单独暴露的/metrics
+	if app.Admin != nil {
+		go func() {
+			if err := app.Admin.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("admin listen error: %s\n", err)
+			}
+		}()
+	}
 	wd, err := os.Getwd()
 	if err != nil {
 		log.Fatal(err)
@@ -52,6 +72,9 @@ func startCmdExculpate(cmd *cobra.Command, args []string) {
 
 	<-quit // 无信号会阻塞
 	fmt.Println("shutdown server ...")
+	// 先让/readyz失败，等待负载均衡摘除后再关闭
+	app.Health.Shutdown()
+	time.Sleep(time.Duration(app.Health.Drain) * time.Second)
 	// 4.3 接收到结束信号，创建5秒超时的上下文
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -59,5 +82,10 @@ func startCmdExculpate(cmd *cobra.Command, args []string) {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("server shutdown: ", err)
 	}
+	if app.Admin != nil {
+		_ = app.Admin.Shutdown(ctx)
+	}
+	// 导出剩余的span等
+	cleanup()
 	fmt.Println("server exiting")
 }
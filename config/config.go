@@ -1,8 +1,11 @@
 package config
 
 import (
+	"api-gin/infra/health"
 	"api-gin/infra/log"
+	"api-gin/infra/metrics"
 	"api-gin/infra/redis"
+	"api-gin/infra/telemetry"
 	"api-gin/repo"
 	"github.com/spf13/viper"
 )
@@ -15,6 +18,10 @@ type Config struct {
 	Log   log.Config       `mapstructure:"log"`
 	MySQL repo.MysqlConfig `mapstructure:"mysql"`
 	Redis redis.Config     `mapstructure:"redis"`
+
+	Telemetry telemetry.Config `mapstructure:"telemetry"`
+	Metrics   metrics.Config   `mapstructure:"metrics"`
+	Health    health.Config    `mapstructure:"health"`
 }
 
 func NewConfig() (*Config, error) {
@@ -27,6 +34,8 @@ func NewConfig() (*Config, error) {
 	viper.SetDefault("logrus.level", "info")
 	viper.SetDefault("logrus.format", "text")
 	viper.SetDefault("logrus.output", "stdout")
+	viper.SetDefault("telemetry.exporter", "stdout")
+	viper.SetDefault("telemetry.sample_ratio", 1)
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
@@ -51,3 +60,19 @@ func GetMySQLConfig(c *Config) repo.MysqlConfig {
 func GetRedisConfig(c *Config) redis.Config {
 	return c.Redis
 }
+
+func GetTelemetryConfig(c *Config) telemetry.Config {
+	t := c.Telemetry
+	if t.ServiceName == "" {
+		t.ServiceName = c.Name
+	}
+	return t
+}
+
+func GetMetricsConfig(c *Config) metrics.Config {
+	return c.Metrics
+}
+
+func GetHealthConfig(c *Config) health.Config {
+	return c.Health
+}
@@ -3,7 +3,6 @@ package controller
 import (
 	"api-gin/handler"
 	"github.com/gin-gonic/gin"
-	"net/http"
 )
 
 type HelloController struct {
@@ -16,20 +15,12 @@ func NewHelloController(h *handler.HelloHandler) *HelloController {
 	}
 }
 
+// Hello 绑定、校验与响应见 Wrap
 func (c *HelloController) Hello(ctx *gin.Context) {
-	var req handler.HelloReq
-	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"message": "参数错误",
-		})
-		return
-	}
-	resp, err := c.h.Hello(ctx, &req)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{
-			"message": "内部错误",
-		})
-		return
-	}
-	ctx.JSON(http.StatusOK, resp)
+	Wrap(c.h.Hello)(ctx)
+}
+
+// List 分页参数见 page.Req
+func (c *HelloController) List(ctx *gin.Context) {
+	Wrap(c.h.List)(ctx)
 }
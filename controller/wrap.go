@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"api-gin/infra/errcode"
+	"api-gin/infra/response"
+	"api-gin/infra/validate"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Wrap 将handler方法适配为gin路由：
+//
+//	1 有请求体时按Content-Type绑定 json、xml、form，再按标签绑定 form(query)、uri、header
+//	2 全部绑定后执行一次 binding 校验
+//	3 校验失败时按 Accept-Language 返回各字段的错误信息
+//	4 调用handler，结果或错误统一以 response 返回
+func Wrap[Req, Resp any](fn func(*gin.Context, *Req) (*Resp, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		req := new(Req)
+		if err := Bind(ctx, req); err != nil {
+			fields := validate.Translate(err, ctx.GetHeader("Accept-Language"))
+			response.FailWithData(ctx, errcode.ErrInvalidParams.Wrap(err), fields)
+			return
+		}
+		resp, err := fn(ctx, req)
+		if err != nil {
+			response.Fail(ctx, err)
+			return
+		}
+		response.Success(ctx, resp)
+	}
+}
+
+// Bind 绑定请求的所有来源到req并校验，req为结构体指针。
+// 先绑定请求体，再以uri、header覆盖，请求体不能改写路由参数和header
+func Bind(ctx *gin.Context, req any) error {
+	withQuery, err := bindBody(ctx, req)
+	if err != nil {
+		return err
+	}
+	// json、xml按字段名匹配，清除请求体写入uri、header字段的值
+	resetTagged(reflect.ValueOf(req), "uri", "header")
+	if !withQuery {
+		if err := binding.MapFormWithTag(req, ctx.Request.URL.Query(), "form"); err != nil {
+			return err
+		}
+	}
+	params := make(map[string][]string, len(ctx.Params))
+	for _, p := range ctx.Params {
+		params[p.Key] = []string{p.Value}
+	}
+	if err := bindTagged(req, params, "uri"); err != nil {
+		return err
+	}
+	// header的key已规范化，同时提供小写形式，兼容 header:"x-token" 的写法
+	headers := make(map[string][]string, len(ctx.Request.Header)*2)
+	for k, v := range ctx.Request.Header {
+		headers[k] = v
+		headers[strings.ToLower(k)] = v
+	}
+	if err := bindTagged(req, headers, "header"); err != nil {
+		return err
+	}
+	if binding.Validator == nil {
+		return nil
+	}
+	// 注册字段名和翻译，需在首次校验前完成
+	if err := validate.Init(); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(req)
+}
+
+// resetTagged 将带有任一tag的字段置为零值，包括嵌入的结构体
+func resetTagged(v reflect.Value, tags ...string) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		switch {
+		case hasTag(f, tags...) && v.Field(i).CanSet():
+			v.Field(i).SetZero()
+		case f.Anonymous:
+			resetTagged(v.Field(i), tags...)
+		}
+	}
+}
+
+// bindTagged 只绑定声明了tag的字段。gin对没有tag的字段按字段名绑定，
+// 先绑定到临时值再复制，避免header、路由参数改写请求体和query的字段
+func bindTagged(req any, source map[string][]string, tag string) error {
+	dst := reflect.ValueOf(req)
+	if dst.Kind() != reflect.Pointer || dst.IsNil() || dst.Elem().Kind() != reflect.Struct {
+		return binding.MapFormWithTag(req, source, tag)
+	}
+	tmp := reflect.New(dst.Elem().Type())
+	if err := binding.MapFormWithTag(tmp.Interface(), source, tag); err != nil {
+		return err
+	}
+	copyTagged(dst.Elem(), tmp.Elem(), tag)
+	return nil
+}
+
+// copyTagged 将src中带有tag的字段复制到dst，包括嵌入的结构体
+func copyTagged(dst, src reflect.Value, tag string) {
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		d, s := dst.Field(i), src.Field(i)
+		if hasTag(f, tag) {
+			if d.CanSet() {
+				d.Set(s)
+			}
+			continue
+		}
+		if !f.Anonymous {
+			continue
+		}
+		if s.Kind() == reflect.Pointer {
+			if s.IsNil() {
+				continue
+			}
+			if d.IsNil() {
+				if !d.CanSet() {
+					continue
+				}
+				d.Set(reflect.New(d.Type().Elem()))
+			}
+			d, s = d.Elem(), s.Elem()
+		}
+		if s.Kind() == reflect.Struct {
+			copyTagged(d, s, tag)
+		}
+	}
+}
+
+// hasTag 字段是否声明了任一tag
+func hasTag(f reflect.StructField, tags ...string) bool {
+	for _, tag := range tags {
+		if name, ok := f.Tag.Lookup(tag); ok && name != "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// bindBody 按Content-Type绑定请求体，不做校验，没有请求体时忽略。
+// 表单请求体与query一起绑定，此时withQuery为true
+func bindBody(ctx *gin.Context, req any) (withQuery bool, err error) {
+	r := ctx.Request
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 || r.Method == http.MethodGet {
+		return false, nil
+	}
+	switch ctx.ContentType() {
+	case binding.MIMEJSON:
+		decoder := json.NewDecoder(r.Body)
+		if binding.EnableDecoderUseNumber {
+			decoder.UseNumber()
+		}
+		if binding.EnableDecoderDisallowUnknownFields {
+			decoder.DisallowUnknownFields()
+		}
+		return false, decoder.Decode(req)
+	case binding.MIMEXML, binding.MIMEXML2:
+		return false, xml.NewDecoder(r.Body).Decode(req)
+	case binding.MIMEPOSTForm:
+		if err := r.ParseForm(); err != nil {
+			return false, err
+		}
+		// r.Form 包含请求体和query，请求体优先
+		return true, binding.MapFormWithTag(req, r.Form, "form")
+	case binding.MIMEMultipartPOSTForm:
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return false, err
+		}
+		return true, binding.MapFormWithTag(req, r.Form, "form")
+	default:
+		return false, fmt.Errorf("不支持的Content-Type：%s", ctx.ContentType())
+	}
+}
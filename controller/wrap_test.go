@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"api-gin/infra/errcode"
+	"api-gin/infra/response"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type wrapReq struct {
+	ID    int64  `uri:"id" binding:"required"`
+	Page  int    `form:"page,default=1"`
+	Token string `header:"x-token" binding:"required"`
+	Name  string `json:"name" form:"name" binding:"required,max=5"`
+}
+
+type wrapResp struct {
+	ID    int64  `json:"id"`
+	Page  int    `json:"page"`
+	Token string `json:"token"`
+	Name  string `json:"name"`
+}
+
+func TestWrap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.POST("/items/:id", Wrap(func(ctx *gin.Context, req *wrapReq) (*wrapResp, error) {
+		if req.Name == "none" {
+			return nil, errcode.ErrNotFound
+		}
+		return &wrapResp{ID: req.ID, Page: req.Page, Token: req.Token, Name: req.Name}, nil
+	}))
+
+	cases := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		token       string
+		status      int
+		want        wrapResp
+	}{
+		{"json", "/items/7?page=2", "application/json", `{"name":"tom"}`, "t1", http.StatusOK, wrapResp{7, 2, "t1", "tom"}},
+		{"form", "/items/8", "application/x-www-form-urlencoded", "name=amy", "t2", http.StatusOK, wrapResp{8, 1, "t2", "amy"}},
+		{"missing header", "/items/7", "application/json", `{"name":"tom"}`, "", http.StatusBadRequest, wrapResp{}},
+		{"invalid body", "/items/7", "application/json", `{"name":"toolong"}`, "t1", http.StatusBadRequest, wrapResp{}},
+		{"invalid uri", "/items/abc", "application/json", `{"name":"tom"}`, "t1", http.StatusBadRequest, wrapResp{}},
+		{"body overrides uri and header", "/items/7", "application/json", `{"id":999,"token":"evil","name":"tom"}`, "good", http.StatusOK, wrapResp{7, 1, "good", "tom"}},
+		{"body fills missing header", "/items/7", "application/json", `{"token":"evil","name":"tom"}`, "", http.StatusBadRequest, wrapResp{}},
+		{"form body and query", "/items/8?page=3", "application/x-www-form-urlencoded", "name=amy&id=999", "t2", http.StatusOK, wrapResp{8, 3, "t2", "amy"}},
+		{"handler error", "/items/7", "application/json", `{"name":"none"}`, "t1", http.StatusNotFound, wrapResp{}},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(c.body))
+		r.Header.Set("Content-Type", c.contentType)
+		if c.token != "" {
+			r.Header.Set("X-Token", c.token)
+		}
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("%s: status = %d, want %d, body %s", c.name, w.Code, c.status, w.Body.String())
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		var resp response.Response
+		data := new(wrapResp)
+		resp.Data = data
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: invalid body %s", c.name, w.Body.String())
+		}
+		if *data != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, *data, c.want)
+		}
+	}
+}
+
+func TestWrapUntaggedFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.POST("/items/:id/:name", Wrap(func(ctx *gin.Context, req *wrapReq) (*wrapResp, error) {
+		return &wrapResp{ID: req.ID, Page: req.Page, Token: req.Token, Name: req.Name}, nil
+	}))
+
+	// 没有header、uri标签的字段不能被同名的header、路由参数改写
+	r := httptest.NewRequest(http.MethodPost, "/items/7/evil?page=2", strings.NewReader(`{"name":"tom"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Token", "t1")
+	r.Header.Set("Name", "evil")
+	r.Header.Set("Page", "99")
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	var resp response.Response
+	data := new(wrapResp)
+	resp.Data = data
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+	if want := (wrapResp{7, 2, "t1", "tom"}); *data != want {
+		t.Errorf("got %+v, want %+v", *data, want)
+	}
+}
+
+func TestWrapValidationErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.POST("/items/:id", Wrap(func(ctx *gin.Context, req *wrapReq) (*wrapResp, error) {
+		return &wrapResp{}, nil
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/items/7", strings.NewReader(`{"name":"toolong"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	var resp struct {
+		Code int `json:"code"`
+		Data []struct {
+			Field   string `json:"field"`
+			Message string `json:"message"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid body %s", w.Body.String())
+	}
+	if w.Code != http.StatusBadRequest || resp.Code != errcode.ErrInvalidParams.Code || len(resp.Data) != 2 {
+		t.Fatalf("unexpected response: %d %s", w.Code, w.Body.String())
+	}
+	if resp.Data[0].Field != "x-token" || resp.Data[1].Field != "name" || resp.Data[1].Message != "name must be a maximum of 5 characters in length" {
+		t.Errorf("unexpected fields: %+v", resp.Data)
+	}
+}
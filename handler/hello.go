@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"api-gin/infra/page"
 	"api-gin/repo"
 	"github.com/gin-gonic/gin"
 )
@@ -22,3 +23,7 @@ func (h *HelloHandler) Hello(ctx *gin.Context, req *HelloReq) (resp *HelloResp,
 		Msg: h.helloRepo.Hello(ctx) + req.Name,
 	}, nil
 }
+
+func (h *HelloHandler) List(ctx *gin.Context, req *HelloListReq) (*page.Resp[repo.HelloItem], error) {
+	return h.helloRepo.List(ctx, req.Req, req.Name)
+}
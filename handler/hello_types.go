@@ -1,5 +1,7 @@
 package handler
 
+import "api-gin/infra/page"
+
 type BaseRepo struct {
 }
 
@@ -10,3 +12,8 @@ type HelloReq struct {
 type HelloResp struct {
 	Msg string `json:"msg"`
 }
+
+type HelloListReq struct {
+	page.Req
+	Name string `form:"name"`
+}
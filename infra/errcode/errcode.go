@@ -0,0 +1,112 @@
+package errcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+/*
+业务错误码：
+	Code：返回给客户端的业务码，0为成功
+	Status：HTTP状态码
+	Message：返回给客户端的提示
+handler返回 *Error 或包装了 *Error 的错误，controller通过 FromError 统一转换
+*/
+
+type Error struct {
+	Code    int
+	Status  int
+	Message string
+	cause   error // 原始错误，不返回给客户端
+}
+
+func New(code, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+var (
+	ErrInternal        = New(10000, http.StatusInternalServerError, "内部错误")
+	ErrInvalidParams   = New(10001, http.StatusBadRequest, "参数错误")
+	ErrUnauthorized    = New(10002, http.StatusUnauthorized, "未登录")
+	ErrForbidden       = New(10003, http.StatusForbidden, "无权限")
+	ErrNotFound        = New(10004, http.StatusNotFound, "数据不存在")
+	ErrConflict        = New(10005, http.StatusConflict, "数据已被修改")
+	ErrTooManyRequests = New(10006, http.StatusTooManyRequests, "请求过于频繁")
+	ErrTimeout         = New(10007, http.StatusGatewayTimeout, "请求超时")
+)
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("[%d] %s: %v", e.Code, e.Message, e.cause)
+	}
+	return fmt.Sprintf("[%d] %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is 业务码相同即为同一错误，errors.Is(err, errcode.ErrNotFound)
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap 复制错误并附带原始错误
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.cause = err
+	return &c
+}
+
+// WithMessage 复制错误并替换提示
+func (e *Error) WithMessage(format string, args ...any) *Error {
+	c := *e
+	c.Message = fmt.Sprintf(format, args...)
+	return &c
+}
+
+type mapping struct {
+	target error
+	code   *Error
+}
+
+var (
+	mu       sync.RWMutex
+	mappings = []mapping{
+		{gorm.ErrRecordNotFound, ErrNotFound},
+		{context.DeadlineExceeded, ErrTimeout},
+	}
+)
+
+// Register 登记底层错误对应的业务错误，如 repo.ErrVersionConflict -> ErrConflict
+func Register(target error, code *Error) {
+	mu.Lock()
+	defer mu.Unlock()
+	mappings = append(mappings, mapping{target: target, code: code})
+}
+
+// FromError 将任意错误转换为业务错误：
+// 链路中有 *Error 时直接使用，否则按登记的底层错误匹配，都不匹配时为 ErrInternal
+func FromError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, m := range mappings {
+		if errors.Is(err, m.target) {
+			return m.code.Wrap(err)
+		}
+	}
+	return ErrInternal.Wrap(err)
+}
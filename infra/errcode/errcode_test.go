@@ -0,0 +1,52 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestFromError(t *testing.T) {
+	errBiz := New(20001, http.StatusBadRequest, "余额不足")
+	errCustom := errors.New("custom")
+	Register(errCustom, ErrForbidden)
+
+	cases := []struct {
+		name   string
+		err    error
+		code   int
+		status int
+	}{
+		{"typed", errBiz, 20001, http.StatusBadRequest},
+		{"wrapped typed", fmt.Errorf("pay: %w", errBiz), 20001, http.StatusBadRequest},
+		{"record not found", fmt.Errorf("get: %w", gorm.ErrRecordNotFound), ErrNotFound.Code, http.StatusNotFound},
+		{"registered", fmt.Errorf("x: %w", errCustom), ErrForbidden.Code, http.StatusForbidden},
+		{"unknown", errors.New("boom"), ErrInternal.Code, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		e := FromError(c.err)
+		if e.Code != c.code || e.Status != c.status {
+			t.Errorf("%s: got code %d status %d, want %d %d", c.name, e.Code, e.Status, c.code, c.status)
+		}
+	}
+	if FromError(nil) != nil {
+		t.Errorf("nil error should map to nil")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("bad id")
+	err := ErrInvalidParams.Wrap(cause)
+	if !errors.Is(err, ErrInvalidParams) || !errors.Is(err, cause) {
+		t.Errorf("wrapped error should match both code and cause")
+	}
+	if ErrInvalidParams.Unwrap() != nil {
+		t.Errorf("Wrap should not modify the original error")
+	}
+	if e := ErrNotFound.WithMessage("用户%d不存在", 1); e.Message != "用户1不存在" || ErrNotFound.Message != "数据不存在" {
+		t.Errorf("unexpected message: %s", e.Message)
+	}
+}
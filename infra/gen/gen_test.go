@@ -0,0 +1,259 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var helloWorld = Table{
+	Name: "hello_world",
+	Columns: []Column{
+		{Name: "id", DataType: "bigint", ColumnType: "bigint(20) unsigned", Nullable: "NO", Key: "PRI", Extra: "auto_increment"},
+		{Name: "name", DataType: "varchar", ColumnType: "varchar(255)", Nullable: "YES", Comment: "名称"},
+		{Name: "create_time", DataType: "datetime", ColumnType: "datetime", Nullable: "YES"},
+		{Name: "update_time", DataType: "datetime", ColumnType: "datetime", Nullable: "YES"},
+		{Name: "enabled", DataType: "tinyint", ColumnType: "tinyint(1)", Nullable: "NO"},
+	},
+}
+
+var helloOptions = ModelOptions{
+	Package:     "model",
+	ModelImport: "api-gin/repo/model",
+	NullImport:  "api-gin/infra/model",
+	LogImport:   "api-gin/infra/log",
+}
+
+func TestCamel(t *testing.T) {
+	cases := map[string]string{
+		"hello_world": "HelloWorld",
+		"user_id":     "UserID",
+		"api_url":     "APIURL",
+		"mystruct":    "Mystruct",
+	}
+	for in, want := range cases {
+		if got := Camel(in); got != want {
+			t.Errorf("Camel(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := LowerCamel("id_card"); got != "idCard" {
+		t.Errorf("LowerCamel(id_card) = %q", got)
+	}
+}
+
+func TestModel(t *testing.T) {
+	src, err := Model(helloWorld, helloOptions)
+	if err != nil {
+		t.Fatalf("Error generate model: %v", err)
+	}
+	code := string(src)
+	for _, want := range []string{
+		"package model",
+		`"api-gin/infra/model"`,
+		"type HelloWorld struct",
+		"ID         uint64                `gorm:\"column:id;type:bigint(20) unsigned;primaryKey;autoIncrement\" json:\"id\"`",
+		"Name       model.Null[string]    `gorm:\"column:name;type:varchar(255)\" json:\"name\"` // 名称",
+		"CreateTime model.Null[time.Time]",
+		"Enabled    bool",
+		"func (h *HelloWorld) TableName() string",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("model missing %q:\n%s", want, code)
+		}
+	}
+}
+
+func TestRepo(t *testing.T) {
+	src, err := Repo(helloWorld, helloOptions)
+	if err != nil {
+		t.Fatalf("Error generate repo: %v", err)
+	}
+	code := string(src)
+	for _, want := range []string{
+		"func NewHelloWorldRepo(db *gorm.DB, logger *log.Logger) *HelloWorldRepo",
+		`NewBaseRepo(db, WithTableName("hello_world"), WithTimestamps())`,
+		"GetByID(ctx context.Context, id uint64) (*model.HelloWorld, error)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("repo missing %q:\n%s", want, code)
+		}
+	}
+}
+
+func TestAddProviders(t *testing.T) {
+	src, err := os.ReadFile("../../server/wire_set.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := AddProviders(src, "repoSet", "repo.NewHelloWorldRepo", "repo.NewUserRepo")
+	if err != nil {
+		t.Fatalf("Error add providers: %v", err)
+	}
+	if !strings.Contains(string(out), "\t\trepo.NewUserRepo,\n\t\trepo.NewHelloWorldRepo,\n\t)") {
+		t.Errorf("provider not appended:\n%s", out)
+	}
+	if strings.Count(string(out), "repo.NewUserRepo") != 1 {
+		t.Errorf("existing provider duplicated")
+	}
+	// 重复执行不变
+	again, err := AddProviders(out, "repoSet", "repo.NewHelloWorldRepo")
+	if err != nil || string(again) != string(out) {
+		t.Errorf("AddProviders should be idempotent: %v", err)
+	}
+	if _, err := AddProviders(src, "serviceSet", "service.NewX"); err == nil {
+		t.Errorf("commented set should not be found")
+	}
+}
+
+func TestAddProvidersWithoutTrailingComma(t *testing.T) {
+	src := []byte(`package server
+
+import "github.com/google/wire"
+
+var (
+	oneLine = wire.NewSet(repo.NewDB, repo.NewUserRepo)
+	multiLine = wire.NewSet(
+		repo.NewDB,
+		repo.NewUserRepo)
+	empty = wire.NewSet()
+)
+`)
+	out := src
+	var err error
+	for _, set := range []string{"oneLine", "multiLine", "empty"} {
+		if out, err = AddProviders(out, set, "repo.NewHelloWorldRepo"); err != nil {
+			t.Fatalf("%s: %v\n%s", set, err, out)
+		}
+	}
+	for _, want := range []string{
+		"oneLine = wire.NewSet(repo.NewDB, repo.NewUserRepo,\n\t\trepo.NewHelloWorldRepo,\n\t)",
+		"\t\trepo.NewUserRepo,\n\t\trepo.NewHelloWorldRepo,\n\t)",
+		"empty = wire.NewSet(\n\t\trepo.NewHelloWorldRepo,\n\t)",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expect %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestModule(t *testing.T) {
+	if _, err := NewModule("api-gin", "Order-Item"); err == nil {
+		t.Errorf("invalid module name should fail")
+	}
+	m, err := NewModule("api-gin", "order_item")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Camel != "OrderItem" || m.Lower != "orderItem" {
+		t.Errorf("unexpected names: %+v", m)
+	}
+	files, err := m.Files()
+	if err != nil {
+		t.Fatalf("Error generate module: %v", err)
+	}
+	if len(files) != 5 {
+		t.Errorf("unexpected files: %d", len(files))
+	}
+	if src := string(files["controller/order_item_controller_test.go"]); !strings.Contains(src, "func TestOrderItemControllerGet(t *testing.T)") {
+		t.Errorf("test not generated:\n%s", src)
+	}
+
+	wireSet, err := os.ReadFile("../../server/wire_set.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := m.Register(wireSet)
+	if err != nil {
+		t.Fatalf("Error register module: %v", err)
+	}
+	for _, want := range []string{
+		"repo.NewOrderItemRepo,",
+		"handler.NewOrderItemHandler,",
+		"controller.NewOrderItemController,",
+		"OrderItemController *controller.OrderItemController",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("wire set missing %q:\n%s", want, out)
+		}
+	}
+	if again, _ := m.Register(out); string(again) != string(out) {
+		t.Errorf("Register should be idempotent")
+	}
+
+	engine, err := os.ReadFile("../../server/engine.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = m.Route(engine)
+	if err != nil {
+		t.Fatalf("Error add route: %v", err)
+	}
+	if !strings.Contains(string(out), "\t{\n\t\torderItemGroup := rGroup.Group(\"/v1/api/order_item\")\n\t\torderItemGroup.GET(\"/:id\", a.Controllers.OrderItemController.Get)\n\t}\n}") {
+		t.Errorf("route not added:\n%s", out)
+	}
+	if again, _ := m.Route(out); string(again) != string(out) {
+		t.Errorf("Route should be idempotent")
+	}
+}
+
+func TestModuleRouteNames(t *testing.T) {
+	engine, err := os.ReadFile("../../server/engine.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与initRouter中的变量同名或为关键字的模块
+	for _, name := range []string{"api", "r_group", "type", "range", "r"} {
+		m, err := NewModule("api-gin", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := m.Route(engine)
+		if err != nil {
+			t.Fatalf("%s: Error add route: %v", name, err)
+		}
+		if err := checkInitRouter(out); err != nil {
+			t.Errorf("%s: %v\n%s", name, err, out)
+		}
+	}
+}
+
+// checkInitRouter 类型检查initRouter中的变量声明，App等外部类型以空接口代替
+func checkInitRouter(src []byte) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return err
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "initRouter" {
+			continue
+		}
+		// 只保留变量声明，检查重复声明和未使用的变量
+		var stub bytes.Buffer
+		stub.WriteString("package p\ntype group struct{}\nfunc (group) Group(string) group { return group{} }\nfunc (group) GET(string, any) {}\n")
+		stub.WriteString("func initRouter(a struct{ Engine struct{ RouterGroup group }; Controllers map[string]any }) ")
+		var body bytes.Buffer
+		if err := format.Node(&body, fset, fn.Body); err != nil {
+			return err
+		}
+		stub.WriteString(routeControllers.ReplaceAllString(body.String(), `a.Controllers["$1"]`))
+		stubFile, err := parser.ParseFile(fset, "", stub.Bytes(), 0)
+		if err != nil {
+			return err
+		}
+		_, err = (&types.Config{}).Check("p", fset, []*ast.File{stubFile}, nil)
+		return err
+	}
+	return fmt.Errorf("未找到initRouter")
+}
+
+var routeControllers = regexp.MustCompile(`a\.Controllers\.(\w+)\.\w+`)
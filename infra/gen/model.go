@@ -0,0 +1,175 @@
+package gen
+
+import (
+	"bytes"
+	"embed"
+	"go/format"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.New("gen").ParseFS(templateFS, "templates/*.tmpl"))
+
+// ModelOptions 生成model、repo时使用的包路径
+type ModelOptions struct {
+	Package     string // model的包名
+	ModelImport string // model的包路径，repo引用
+	NullImport  string // model.Null所在的包路径
+	LogImport   string // log所在的包路径，repo引用
+}
+
+type field struct {
+	Name    string
+	Type    string
+	Tag     string
+	JSON    string
+	Comment string
+}
+
+type modelData struct {
+	ModelOptions
+	Table    string
+	Struct   string
+	Receiver string
+	Comment  string
+	Imports  []string
+	Fields   []field
+	PK       *field
+	Options  []string
+}
+
+// goType 字段对应的Go类型，不含Null包装
+func goType(c Column) string {
+	unsigned := strings.Contains(c.ColumnType, "unsigned")
+	switch c.DataType {
+	case "tinyint":
+		if strings.HasPrefix(c.ColumnType, "tinyint(1)") {
+			return "bool"
+		}
+		return "int"
+	case "smallint", "mediumint", "int", "integer":
+		if unsigned {
+			return "uint"
+		}
+		return "int"
+	case "bigint":
+		if unsigned {
+			return "uint64"
+		}
+		return "int64"
+	case "float":
+		return "float32"
+	case "double", "decimal":
+		return "float64"
+	case "date", "datetime", "timestamp":
+		return "time.Time"
+	case "binary", "varbinary", "blob", "tinyblob", "mediumblob", "longblob", "bit":
+		return "[]byte"
+	default:
+		// char、varchar、text、enum、set、json、time等
+		return "string"
+	}
+}
+
+func newModelData(t Table, o ModelOptions) modelData {
+	d := modelData{
+		ModelOptions: o,
+		Table:        t.Name,
+		Struct:       Camel(t.Name),
+		Comment:      t.Comment,
+	}
+	d.Receiver = strings.ToLower(d.Struct[:1])
+
+	var useTime, useNull bool
+	columns := make(map[string]bool)
+	for _, c := range t.Columns {
+		columns[c.Name] = true
+		typ := goType(c)
+		useTime = useTime || typ == "time.Time"
+		if c.IsNullable() && !c.IsPrimary() {
+			typ = "model.Null[" + typ + "]"
+			useNull = true
+		}
+		tag := "column:" + c.Name + ";type:" + c.ColumnType
+		if c.IsPrimary() {
+			tag += ";primaryKey"
+		}
+		if strings.Contains(c.Extra, "auto_increment") {
+			tag += ";autoIncrement"
+		}
+		f := field{
+			Name:    Camel(c.Name),
+			Type:    typ,
+			Tag:     tag,
+			JSON:    c.Name,
+			Comment: strings.ReplaceAll(c.Comment, "\n", " "),
+		}
+		d.Fields = append(d.Fields, f)
+		if c.IsPrimary() {
+			if d.PK == nil {
+				d.PK = &f
+			} else {
+				// 联合主键不生成按主键查询
+				d.PK = &field{}
+			}
+		}
+	}
+	if d.PK != nil && d.PK.Name == "" {
+		d.PK = nil
+	}
+
+	if useNull {
+		d.Imports = append(d.Imports, o.NullImport)
+	}
+	if useTime {
+		d.Imports = append(d.Imports, "time")
+	}
+
+	// 与repo的通用字段约定一致时开启对应选项
+	if columns["create_time"] && columns["update_time"] {
+		d.Options = append(d.Options, "WithTimestamps()")
+	}
+	if columns["deleted_at"] {
+		d.Options = append(d.Options, "WithSoftDelete()")
+	}
+	if columns["version"] {
+		d.Options = append(d.Options, "WithVersion()")
+	}
+	return d
+}
+
+// Model 生成表对应的model文件
+func Model(t Table, o ModelOptions) ([]byte, error) {
+	return render("model.tmpl", newModelData(t, o))
+}
+
+// Repo 生成表对应的repo骨架，基于NewBaseRepo
+func Repo(t Table, o ModelOptions) ([]byte, error) {
+	return render("repo.tmpl", newModelData(t, o))
+}
+
+// RepoProvider repo的构造函数，用于加入wire的repoSet
+func RepoProvider(t Table) string {
+	return "repo.New" + Camel(t.Name) + "Repo"
+}
+
+// ModelFile model的文件名
+func ModelFile(t Table) string {
+	return t.Name + "_model.go"
+}
+
+// RepoFile repo的文件名
+func RepoFile(t Table) string {
+	return t.Name + "_repo.go"
+}
+
+func render(name string, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
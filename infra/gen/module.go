@@ -0,0 +1,128 @@
+package gen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var moduleNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Module 一个业务模块，生成controller、handler、请求响应类型、repo、测试
+type Module struct {
+	Import string // go module路径，如 api-gin
+	Name   string // 模块名，下划线命名，如 order_item
+	Camel  string // OrderItem
+	Lower  string // orderItem
+	Path   string // 路由前缀，如 /v1/api/order_item
+}
+
+func NewModule(module, name string) (*Module, error) {
+	if !moduleNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("模块名只能包含小写字母、数字和下划线：%s", name)
+	}
+	return &Module{
+		Import: module,
+		Name:   name,
+		Camel:  Camel(name),
+		Lower:  LowerCamel(name),
+		Path:   "/v1/api/" + name,
+	}, nil
+}
+
+// Files 模块的文件，key为相对项目根目录的路径
+func (m *Module) Files() (map[string][]byte, error) {
+	files := map[string]string{
+		filepath.Join("controller", m.Name+"_controller.go"):      "module_controller.tmpl",
+		filepath.Join("controller", m.Name+"_controller_test.go"): "module_controller_test.tmpl",
+		filepath.Join("handler", m.Name+".go"):                    "module_handler.tmpl",
+		filepath.Join("handler", m.Name+"_types.go"):              "module_types.tmpl",
+		filepath.Join("repo", m.Name+"_repo.go"):                  "module_repo.tmpl",
+	}
+	result := make(map[string][]byte, len(files))
+	for path, tmpl := range files {
+		src, err := render(tmpl, m)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", tmpl, err)
+		}
+		result[path] = src
+	}
+	return result, nil
+}
+
+// Register 将模块注册到wire_set.go的各个集合和Controllers中
+func (m *Module) Register(src []byte) ([]byte, error) {
+	var err error
+	for set, provider := range map[string]string{
+		"repoSet":       "repo.New" + m.Camel + "Repo",
+		"handlerSet":    "handler.New" + m.Camel + "Handler",
+		"controllerSet": "controller.New" + m.Camel + "Controller",
+	} {
+		if src, err = AddProviders(src, set, provider); err != nil {
+			return nil, err
+		}
+	}
+	return AddField(src, "Controllers", m.Camel+"Controller", "*controller."+m.Camel+"Controller")
+}
+
+// Route 将模块的路由加入engine.go的initRouter。
+// 路由放在单独的代码块中，变量名加Group后缀，不与rGroup、api等已有变量及关键字冲突
+func (m *Module) Route(src []byte) ([]byte, error) {
+	if strings.Contains(string(src), "a.Controllers."+m.Camel+"Controller.") {
+		return src, nil
+	}
+	group := m.Lower + "Group"
+	route := fmt.Sprintf("{\n%s := rGroup.Group(%q)\n%s.GET(\"/:id\", a.Controllers.%sController.Get)\n}\n",
+		group, m.Path, group, m.Camel)
+	return AppendToFunc(src, "initRouter", route)
+}
+
+// AddField 向结构体name追加字段，已存在同名字段时忽略
+func AddField(src []byte, name, field, typ string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	obj := file.Scope.Lookup(name)
+	if obj == nil {
+		return nil, fmt.Errorf("未找到结构体：%s", name)
+	}
+	spec, ok := obj.Decl.(*ast.TypeSpec)
+	if !ok {
+		return nil, fmt.Errorf("未找到结构体：%s", name)
+	}
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("%s不是结构体", name)
+	}
+	for _, f := range st.Fields.List {
+		for _, n := range f.Names {
+			if n.Name == field {
+				return src, nil
+			}
+		}
+	}
+	return apply(src, []edit{{offset: fset.Position(st.Fields.Closing).Offset, text: field + " " + typ + "\n"}})
+}
+
+// AppendToFunc 在函数name的末尾追加代码
+func AppendToFunc(src []byte, name, code string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != name || fn.Body == nil {
+			continue
+		}
+		return apply(src, []edit{{offset: fset.Position(fn.Body.Rbrace).Offset, text: code}})
+	}
+	return nil, fmt.Errorf("未找到函数：%s", name)
+}
@@ -0,0 +1,91 @@
+package gen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// initialisms 转换为Go命名时整体大写的缩写
+var initialisms = map[string]bool{
+	"id": true, "ip": true, "url": true, "uri": true, "uuid": true,
+	"api": true, "http": true, "json": true, "sql": true, "html": true,
+}
+
+// Camel 下划线命名转大驼峰，如 hello_world -> HelloWorld，user_id -> UserID
+func Camel(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' '
+	}) {
+		if initialisms[strings.ToLower(part)] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+// ModulePath 向上查找go.mod，返回module路径和go.mod所在目录
+func ModulePath(dir string) (module, root string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "module" {
+					return strings.Trim(fields[1], `"`), dir, nil
+				}
+			}
+			return "", "", fmt.Errorf("go.mod中没有module：%s", dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", os.ErrNotExist
+		}
+		dir = parent
+	}
+}
+
+// ImportPath 目录dir对应的包路径
+func ImportPath(dir string) (string, error) {
+	module, root, err := ModulePath(dir)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return module, nil
+	}
+	return module + "/" + filepath.ToSlash(rel), nil
+}
+
+// LowerCamel 下划线命名转小驼峰，如 hello_world -> helloWorld，id_card -> idCard
+func LowerCamel(name string) string {
+	s := Camel(name)
+	if s == "" {
+		return s
+	}
+	// 开头的缩写整体小写
+	if first := strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == ' ' })[0]; initialisms[strings.ToLower(first)] {
+		return strings.ToLower(first) + s[len(first):]
+	}
+	runes := []rune(s)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,62 @@
+package gen
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Table information_schema中的表结构
+type Table struct {
+	Name    string
+	Comment string
+	Columns []Column
+}
+
+// Column information_schema中的字段
+type Column struct {
+	Name       string `gorm:"column:COLUMN_NAME"`
+	DataType   string `gorm:"column:DATA_TYPE"`   // int、varchar等
+	ColumnType string `gorm:"column:COLUMN_TYPE"` // int(11) unsigned、varchar(255)等
+	Nullable   string `gorm:"column:IS_NULLABLE"`
+	Key        string `gorm:"column:COLUMN_KEY"`
+	Extra      string `gorm:"column:EXTRA"`
+	Comment    string `gorm:"column:COLUMN_COMMENT"`
+}
+
+func (c Column) IsNullable() bool {
+	return c.Nullable == "YES"
+}
+
+func (c Column) IsPrimary() bool {
+	return c.Key == "PRI"
+}
+
+// LoadTables 从当前库的information_schema读取表结构，按tables的顺序返回
+func LoadTables(ctx context.Context, db *gorm.DB, tables []string) ([]Table, error) {
+	result := make([]Table, 0, len(tables))
+	for _, name := range tables {
+		var comment []string
+		err := db.WithContext(ctx).
+			Raw("SELECT TABLE_COMMENT FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", name).
+			Scan(&comment).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(comment) == 0 {
+			return nil, fmt.Errorf("表不存在：%s", name)
+		}
+
+		t := Table{Name: name, Comment: comment[0]}
+		err = db.WithContext(ctx).
+			Raw("SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY, EXTRA, COLUMN_COMMENT "+
+				"FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", name).
+			Scan(&t.Columns).Error
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package gen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// edit 在源码offset处插入text
+type edit struct {
+	offset int
+	text   string
+}
+
+// apply 按offset倒序插入，避免前面的插入影响后面的位置，最后格式化
+func apply(src []byte, edits []edit) ([]byte, error) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].offset > edits[j].offset
+	})
+	out := append([]byte(nil), src...)
+	for _, e := range edits {
+		out = append(out[:e.offset], append([]byte(e.text), out[e.offset:]...)...)
+	}
+	return format.Source(out)
+}
+
+// AddProviders 向wire_set.go中名为set的 wire.NewSet(...) 追加provider，已存在的忽略
+func AddProviders(src []byte, set string, providers ...string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	call := findSet(file, set)
+	if call == nil {
+		return nil, fmt.Errorf("未找到wire集合：%s", set)
+	}
+
+	exists := make(map[string]bool)
+	for _, arg := range call.Args {
+		exists[types.ExprString(arg)] = true
+	}
+	added := make([]string, 0, len(providers))
+	for _, p := range providers {
+		if !exists[p] {
+			exists[p] = true
+			added = append(added, p)
+		}
+	}
+	if len(added) == 0 {
+		return src, nil
+	}
+	text := strings.Join(added, ",\n") + ",\n"
+	if len(call.Args) == 0 {
+		return apply(src, []edit{{offset: fset.Position(call.Rparen).Offset, text: "\n" + text}})
+	}
+	// 插入到最后一个参数之后，没有结尾的逗号时补上
+	offset := fset.Position(call.Args[len(call.Args)-1].End()).Offset
+	if i := skipSpace(src, offset); i < len(src) && src[i] == ',' {
+		offset = i + 1
+		text = "\n" + text
+	} else {
+		text = ",\n" + text
+	}
+	return apply(src, []edit{{offset: offset, text: text}})
+}
+
+// skipSpace 返回offset之后第一个非空白字符的位置
+func skipSpace(src []byte, offset int) int {
+	for offset < len(src) && (src[offset] == ' ' || src[offset] == '\t' || src[offset] == '\n' || src[offset] == '\r') {
+		offset++
+	}
+	return offset
+}
+
+// findSet 查找 set = wire.NewSet(...) 的调用
+func findSet(file *ast.File, set string) *ast.CallExpr {
+	var found *ast.CallExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok || found != nil {
+			return found == nil
+		}
+		for i, name := range spec.Names {
+			if name.Name != set || i >= len(spec.Values) {
+				continue
+			}
+			if call, ok := spec.Values[i].(*ast.CallExpr); ok && types.ExprString(call.Fun) == "wire.NewSet" {
+				found = call
+			}
+		}
+		return false
+	})
+	return found
+}
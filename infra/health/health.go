@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+健康检查，由App注册：
+	/healthz：存活，进程能处理请求即返回200
+	/readyz：就绪，并发执行各依赖的检查，任一失败或开始优雅关闭后返回503
+*/
+
+const (
+	StatusUp           = "up"
+	StatusDown         = "down"
+	StatusShuttingDown = "shutting_down"
+)
+
+type Config struct {
+	Timeout int `mapstructure:"timeout"` // 单个检查的超时，单位秒，默认1秒
+	Drain   int `mapstructure:"drain"`   // 开始关闭后，等待负载均衡摘除的时间，单位秒
+}
+
+// Check 依赖检查，如 ping 数据库，返回nil为可用
+type Check func(ctx context.Context) error
+
+// CheckResult 单个检查的结果
+type CheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Result 就绪检查的结果
+type Result struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type Health struct {
+	Config
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+func NewHealth(c Config) *Health {
+	if c.Timeout <= 0 {
+		c.Timeout = 1
+	}
+	return &Health{Config: c}
+}
+
+// Register 添加就绪检查，需在开始处理请求前调用
+func (h *Health) Register(name string, check Check) {
+	h.checks = append(h.checks, namedCheck{name: name, check: check})
+}
+
+// Shutdown 标记开始关闭，之后就绪检查均失败
+func (h *Health) Shutdown() {
+	h.shuttingDown.Store(true)
+}
+
+func (h *Health) ShuttingDown() bool {
+	return h.shuttingDown.Load()
+}
+
+// Ready 并发执行所有检查，每个检查单独超时；ok为false时不可接收流量
+func (h *Health) Ready(ctx context.Context) (result Result, ok bool) {
+	if h.ShuttingDown() {
+		return Result{Status: StatusShuttingDown, Checks: []CheckResult{}}, false
+	}
+
+	timeout := time.Duration(h.Timeout) * time.Second
+	result.Checks = make([]CheckResult, len(h.checks))
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			result.Checks[i] = run(ctx, c, timeout)
+		}(i, c)
+	}
+	wg.Wait()
+
+	ok = true
+	result.Status = StatusUp
+	for _, c := range result.Checks {
+		if c.Status != StatusUp {
+			ok = false
+			result.Status = StatusDown
+		}
+	}
+	return result, ok
+}
+
+func run(ctx context.Context, c namedCheck, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.check(ctx)
+	}()
+	// 检查未处理ctx时也按超时返回
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	r := CheckResult{Name: c.name, Status: StatusUp, Latency: time.Since(start).Round(time.Microsecond).String()}
+	if err != nil {
+		r.Status = StatusDown
+		r.Error = err.Error()
+	}
+	return r
+}
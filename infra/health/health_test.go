@@ -0,0 +1,77 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReady(t *testing.T) {
+	h := NewHealth(Config{})
+	h.Register("master-0", func(ctx context.Context) error { return nil })
+	h.Register("redis", func(ctx context.Context) error { return nil })
+
+	result, ok := h.Ready(context.Background())
+	if !ok || result.Status != StatusUp {
+		t.Fatalf("expect up, got %+v", result)
+	}
+	if len(result.Checks) != 2 || result.Checks[0].Name != "master-0" || result.Checks[1].Name != "redis" {
+		t.Errorf("unexpected checks: %+v", result.Checks)
+	}
+}
+
+func TestReadyFailed(t *testing.T) {
+	h := NewHealth(Config{})
+	h.Register("master-0", func(ctx context.Context) error { return nil })
+	h.Register("replica-1", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	result, ok := h.Ready(context.Background())
+	if ok || result.Status != StatusDown {
+		t.Fatalf("expect down, got %+v", result)
+	}
+	if result.Checks[0].Status != StatusUp {
+		t.Errorf("master-0 should be up: %+v", result.Checks[0])
+	}
+	if c := result.Checks[1]; c.Status != StatusDown || c.Error != "connection refused" {
+		t.Errorf("replica-1 should be down: %+v", c)
+	}
+}
+
+func TestReadyTimeout(t *testing.T) {
+	h := NewHealth(Config{Timeout: 1})
+	block := make(chan struct{})
+	defer close(block)
+	// 不处理ctx的检查也按超时返回
+	h.Register("redis", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+
+	start := time.Now()
+	result, ok := h.Ready(context.Background())
+	if ok || result.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("expect timeout, got %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timeout not applied: %s", elapsed)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	h := NewHealth(Config{})
+	called := false
+	h.Register("master-0", func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	h.Shutdown()
+
+	result, ok := h.Ready(context.Background())
+	if ok || result.Status != StatusShuttingDown {
+		t.Fatalf("expect shutting down, got %+v", result)
+	}
+	if called {
+		t.Errorf("checks should not run after shutdown")
+	}
+}
@@ -3,23 +3,18 @@ package log
 import (
 	"context"
 	"fmt"
-	"github.com/google/uuid"
 	"github.com/lestrrat-go/file-rotatelogs"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"os"
 	"runtime"
-	"sync"
 	"time"
 )
 
 type Logger struct {
 	*logrus.Entry
 
-	mu          sync.Mutex // 保证顺序性
-	lastTraceId string     // 多端同一次请求
-	lastSpan    uint       // 单次请求的调用链
-
 	skipCall int // 找到业务调用者所需的层级
 }
 
@@ -86,36 +81,44 @@ func NewLogger(c Config) (*Logger, error) {
 func (l *Logger) NewLogger(call string) *Logger {
 	entry := l.Entry.WithField("caller", call)
 	return &Logger{
-		Entry: entry,
+		Entry:    entry,
+		skipCall: l.skipCall,
 	}
 }
 
 type KeyTraceKey struct{}
 
-// SetTraceId 设置traceId
+// SetTraceId 新建trace，用于没有请求头的场景，如定时任务
 func SetTraceId(ctx context.Context) context.Context {
-	return context.WithValue(ctx, KeyTraceKey{}, uuid.New().String())
+	return ContextWithSpan(ctx, SpanContext{TraceId: newId(16), SpanId: newId(8), Sampled: true})
 }
 
-// Trace 自动增加traceId
+// Trace 输出当前span的traceId、spanId、父spanId，ctx中没有span时新建traceId
 func (l *Logger) Trace(ctx context.Context) logrus.Fields {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	nowTraceIdStr := ""
-	if nowTraceId := ctx.Value(KeyTraceKey{}); nowTraceId != nil {
-		if nowTraceId, ok := nowTraceId.(string); ok {
-			nowTraceIdStr = nowTraceId
+	fields := logrus.Fields{"caller": getCaller(l.skipCall)}
+	span, ok := SpanFromContext(ctx)
+	if sc := trace.SpanContextFromContext(ctx); !ok && sc.IsValid() {
+		// 由OpenTelemetry直接创建的span，如其他库的instrumentation
+		span, ok = SpanContext{TraceId: sc.TraceID().String(), SpanId: sc.SpanID().String()}, true
+	}
+	if !ok {
+		// 兼容只设置了KeyTraceKey的ctx
+		if traceId := TraceId(ctx); traceId != "" {
+			fields["trace_id"] = traceId
+		} else {
+			fields["trace_id"] = newId(16)
 		}
+		return fields
 	}
-	if nowTraceIdStr == "" {
-		nowTraceIdStr = uuid.New().String()
+	fields["trace_id"] = span.TraceId
+	fields["span_id"] = span.SpanId
+	if span.ParentId != "" {
+		fields["parent_span_id"] = span.ParentId
 	}
-	if nowTraceIdStr != l.lastTraceId {
-		l.lastTraceId = nowTraceIdStr
-		l.lastSpan = 0
+	if span.Name != "" {
+		fields["span_name"] = span.Name
 	}
-	l.lastSpan++
-	return logrus.Fields{"trace_id": nowTraceIdStr, "span": l.lastSpan, "caller": getCaller(l.skipCall)}
+	return fields
 }
 
 // getCaller 获取调用者信息
@@ -181,3 +184,8 @@ func (l *Logger) Panic(ctx context.Context, args ...any) {
 func (l *Logger) Panicf(ctx context.Context, format string, args ...any) {
 	l.Entry.WithFields(l.Trace(ctx)).Panicf(format, args...)
 }
+
+// LogFields 附带结构化字段输出日志，如访问日志
+func (l *Logger) LogFields(ctx context.Context, level logrus.Level, fields logrus.Fields, args ...any) {
+	l.Entry.WithFields(l.Trace(ctx)).WithFields(fields).Log(level, args...)
+}
@@ -0,0 +1,150 @@
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+/*
+W3C Trace Context：
+	traceparent: 00-<trace_id 32位hex>-<span_id 16位hex>-<flags 2位hex>
+	请求入口通过 Extract 解析上游的traceparent，再以 StartSpan 创建本服务的span，没有上游时新建trace
+	函数内通过 StartSpan 创建子span
+	调用下游时通过 Inject 或 NewTransport 写入traceparent，下游的父span即当前span
+*/
+
+// HeaderTraceparent W3C traceparent请求头
+const HeaderTraceparent = "traceparent"
+
+// KeySpan 当前span
+type KeySpan struct{}
+
+// SpanContext 一个span的标识
+type SpanContext struct {
+	TraceId  string
+	SpanId   string
+	ParentId string // 父span，为空表示根span
+	Name     string
+	Sampled  bool
+}
+
+// Traceparent W3C traceparent格式
+func (s SpanContext) Traceparent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceId + "-" + s.SpanId + "-" + flags
+}
+
+// SpanStarter 创建子span，返回的func在span结束时调用，可替换为OpenTelemetry等实现
+var SpanStarter = func(ctx context.Context, name string) (context.Context, func()) {
+	parent, ok := SpanFromContext(ctx)
+	span := SpanContext{TraceId: parent.TraceId, SpanId: newId(8), ParentId: parent.SpanId, Name: name, Sampled: parent.Sampled}
+	if !ok {
+		span = SpanContext{TraceId: newId(16), SpanId: span.SpanId, Name: name, Sampled: true}
+	}
+	return ContextWithSpan(ctx, span), func() {}
+}
+
+// StartSpan 在当前span下创建子span，用法：ctx, end := log.StartSpan(ctx, "UserRepo.Hello"); defer end()
+func StartSpan(ctx context.Context, name string) (context.Context, func()) {
+	return SpanStarter(ctx, name)
+}
+
+// ContextWithSpan 设置当前span，同时写入KeyTraceKey兼容只读取traceId的代码
+func ContextWithSpan(ctx context.Context, span SpanContext) context.Context {
+	ctx = context.WithValue(ctx, KeyTraceKey{}, span.TraceId)
+	return context.WithValue(ctx, KeySpan{}, span)
+}
+
+// SpanFromContext 当前span
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	span, ok := ctx.Value(KeySpan{}).(SpanContext)
+	return span, ok
+}
+
+// TraceId 当前的traceId，没有时为空
+func TraceId(ctx context.Context) string {
+	if span, ok := SpanFromContext(ctx); ok {
+		return span.TraceId
+	}
+	id, _ := ctx.Value(KeyTraceKey{}).(string)
+	return id
+}
+
+// ParseTraceparent 解析traceparent，格式错误或id全为0时返回false
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	// 版本00只有4段，更高版本允许追加字段
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	traceId, spanId, flags := parts[1], parts[2], parts[3]
+	if !isHex(parts[0], 2) || !isHex(traceId, 32) || !isHex(spanId, 16) || !isHex(flags, 2) ||
+		traceId == strings.Repeat("0", 32) || spanId == strings.Repeat("0", 16) {
+		return SpanContext{}, false
+	}
+	b, _ := hex.DecodeString(flags)
+	return SpanContext{TraceId: traceId, SpanId: spanId, Sampled: b[0]&1 == 1}, true
+}
+
+// Extract 将请求头中的traceparent作为之后StartSpan的父span，没有或格式错误时不处理，StartSpan会新建trace
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if parent, ok := ParseTraceparent(header.Get(HeaderTraceparent)); ok {
+		ctx = ContextWithSpan(ctx, parent)
+	}
+	return ctx
+}
+
+// Inject 将当前span写入请求头，供下游服务继续trace
+func Inject(ctx context.Context, header http.Header) {
+	if span, ok := SpanFromContext(ctx); ok {
+		header.Set(HeaderTraceparent, span.Traceparent())
+	}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// NewTransport 调用下游时为每个请求创建子span并写入traceparent，base为空时使用http.DefaultTransport
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, end := StartSpan(req.Context(), req.Method+" "+req.URL.Host)
+	defer end()
+	// RoundTrip不能修改原请求
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+	return t.base.RoundTrip(req)
+}
+
+func newId(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	span, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok || span.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || span.SpanId != "00f067aa0ba902b7" || !span.Sampled {
+		t.Errorf("unexpected span: %+v", span)
+	}
+	if span.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("unexpected traceparent: %s", span.Traceparent())
+	}
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, ok := ParseTraceparent(invalid); ok {
+			t.Errorf("%q should be invalid", invalid)
+		}
+	}
+}
+
+func TestStartSpan(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, end := StartSpan(Extract(context.Background(), header), "GET /hello")
+	defer end()
+	server, _ := SpanFromContext(ctx)
+	if server.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentId != "00f067aa0ba902b7" {
+		t.Errorf("unexpected server span: %+v", server)
+	}
+
+	child, endChild := StartSpan(ctx, "UserRepo.Hello")
+	defer endChild()
+	span, _ := SpanFromContext(child)
+	if span.TraceId != server.TraceId || span.ParentId != server.SpanId || span.SpanId == server.SpanId {
+		t.Errorf("unexpected child span: %+v", span)
+	}
+	if TraceId(child) != server.TraceId {
+		t.Errorf("TraceId mismatch")
+	}
+
+	// 没有上游时新建trace
+	rootCtx, _ := StartSpan(Extract(context.Background(), http.Header{}), "GET /hello")
+	root, _ := SpanFromContext(rootCtx)
+	if len(root.TraceId) != 32 || root.ParentId != "" {
+		t.Errorf("unexpected root span: %+v", root)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(HeaderTraceparent)
+	}))
+	defer srv.Close()
+
+	ctx := SetTraceId(context.Background())
+	parent, _ := SpanFromContext(ctx)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	client := &http.Client{Transport: NewTransport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	span, ok := ParseTraceparent(got)
+	if !ok || span.TraceId != parent.TraceId || span.SpanId == parent.SpanId {
+		t.Errorf("unexpected downstream traceparent: %q", got)
+	}
+	if req.Header.Get(HeaderTraceparent) != "" {
+		t.Errorf("original request should not be modified")
+	}
+}
+
+func TestTraceConcurrent(t *testing.T) {
+	logger, _ := NewLogger(Config{Level: "info", Format: "json"})
+	buf := new(bytes.Buffer)
+	var mu sync.Mutex
+	logger.Logger.SetOutput(writerFunc(func(p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return buf.Write(p)
+	}))
+
+	var wg sync.WaitGroup
+	spans := make([]SpanContext, 10)
+	for i := range spans {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, end := StartSpan(SetTraceId(context.Background()), "worker")
+			defer end()
+			spans[i], _ = SpanFromContext(ctx)
+			logger.Info(ctx, "work")
+		}()
+	}
+	wg.Wait()
+
+	byTrace := make(map[string]string)
+	for _, s := range spans {
+		byTrace[s.TraceId] = s.SpanId
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if byTrace[entry["trace_id"].(string)] != entry["span_id"] {
+			t.Errorf("span mismatch: %v", entry)
+		}
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
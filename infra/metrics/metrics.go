@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+/*
+Prometheus指标，开启后由App注册：
+	http_requests_total、http_request_duration_seconds：按方法、路由模板、状态码
+	db_query_duration_seconds：按操作、读写(read/write)、表
+	go_sql_*：每个主从实例的连接池，db_name为实例名，如 master-0、replica-1
+	redis_command_duration_seconds：按命令
+	go_*、process_*：运行时
+*/
+
+type Config struct {
+	Enable bool   `mapstructure:"enable"`
+	Path   string `mapstructure:"path"` // 默认 /metrics
+	Addr   string `mapstructure:"addr"` // 单独的管理端口，如 :9090，为空时挂在App.Engine上
+}
+
+// Metrics 未开启时各指标为空，调用方通过Enabled判断
+type Metrics struct {
+	Config
+	Registry      *prometheus.Registry
+	HTTPRequests  *prometheus.CounterVec
+	HTTPDuration  *prometheus.HistogramVec
+	DBDuration    *prometheus.HistogramVec
+	RedisDuration *prometheus.HistogramVec
+}
+
+func NewMetrics(c Config) (*Metrics, error) {
+	if c.Path == "" {
+		c.Path = "/metrics"
+	}
+	m := &Metrics{Config: c}
+	if !c.Enable {
+		return m, nil
+	}
+
+	m.Registry = prometheus.NewRegistry()
+	m.HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP请求数",
+	}, []string{"method", "route", "status"})
+	m.HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP请求耗时",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	m.DBDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "SQL执行耗时",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "resolver", "table"})
+	m.RedisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_command_duration_seconds",
+		Help:    "redis命令耗时",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5},
+	}, []string{"command"})
+
+	for _, c := range []prometheus.Collector{
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.HTTPRequests,
+		m.HTTPDuration,
+		m.DBDuration,
+		m.RedisDuration,
+	} {
+		if err := m.Registry.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) Enabled() bool {
+	return m != nil && m.Registry != nil
+}
+
+// Handler 输出指标的http.Handler
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// RegisterDBStats 注册连接池的指标，name区分实例
+func (m *Metrics) RegisterDBStats(name string, db *sql.DB) error {
+	return m.Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewMetricsDisabled(t *testing.T) {
+	m, err := NewMetrics(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Enabled() {
+		t.Errorf("expect disabled")
+	}
+	if m.Path != "/metrics" {
+		t.Errorf("expect default path, got %s", m.Path)
+	}
+	var nilMetrics *Metrics
+	if nilMetrics.Enabled() {
+		t.Errorf("nil metrics should be disabled")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m, err := NewMetrics(Config{Enable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.HTTPRequests.WithLabelValues("GET", "/items/:id", "200").Inc()
+	m.RedisDuration.WithLabelValues("get").Observe(0.001)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expect 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/items/:id",status="200"} 1`,
+		`redis_command_duration_seconds_count{command="get"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expect %q in output", want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+/*
+表的通用字段，嵌入model使用，配合repo的选项自动维护：
+	Timestamps：repo.WithTimestamps，新增时填充create_time、update_time，更新时填充update_time
+	SoftDelete：repo.WithSoftDelete，删除时填充deleted_at，查询时过滤已删除的数据
+	Version：repo.WithVersion，更新时校验并递增version，冲突返回repo.ConflictError
+*/
+
+type Timestamps struct {
+	CreateTime Null[time.Time] `gorm:"column:create_time;type:datetime" json:"create_time"`
+	UpdateTime Null[time.Time] `gorm:"column:update_time;type:datetime" json:"update_time"`
+}
+
+type SoftDelete struct {
+	DeletedAt Null[time.Time] `gorm:"column:deleted_at;type:datetime" json:"-"`
+}
+
+type Version struct {
+	Version int64 `gorm:"column:version;type:bigint(20)" json:"version"`
+}
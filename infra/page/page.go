@@ -0,0 +1,79 @@
+package page
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	DefaultSize = 20  // 默认每页条数
+	MaxSize     = 100 // 每页最大条数
+)
+
+// Req 分页请求，controller 从 query 绑定，可嵌入到具体的请求类型中
+type Req struct {
+	Page   int    `form:"page" json:"page"`     // 页码，从1开始，使用游标时忽略
+	Size   int    `form:"size" json:"size"`     // 每页条数
+	Cursor string `form:"cursor" json:"cursor"` // 游标，上一页返回的 next_cursor，首页为空
+}
+
+// GetPage 页码，小于1时为1
+func (r Req) GetPage() int {
+	if r.Page < 1 {
+		return 1
+	}
+	return r.Page
+}
+
+// GetSize 每页条数，限制在[1, MaxSize]，未设置时为 DefaultSize
+func (r Req) GetSize() int {
+	switch {
+	case r.Size <= 0:
+		return DefaultSize
+	case r.Size > MaxSize:
+		return MaxSize
+	default:
+		return r.Size
+	}
+}
+
+// Offset 偏移量
+func (r Req) Offset() int {
+	return (r.GetPage() - 1) * r.GetSize()
+}
+
+// Resp 分页响应，handler 统一返回该结构
+type Resp[T any] struct {
+	List       []T    `json:"list"`
+	Total      int64  `json:"total"`                 // 总数，游标分页时为0
+	Page       int    `json:"page,omitempty"`        // 当前页码，游标分页时为空
+	Size       int    `json:"size"`                  // 每页条数
+	NextCursor string `json:"next_cursor,omitempty"` // 下一页游标，游标分页时返回
+	HasMore    bool   `json:"has_more"`              // 是否还有下一页
+}
+
+// EncodeCursor 将最后一条记录的排序键编码为不透明的游标
+func EncodeCursor(last any) (string, error) {
+	data, err := json.Marshal(last)
+	if err != nil {
+		return "", fmt.Errorf("游标编码错误：%w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor 解析游标，数字保留为 json.Number 防止丢失精度
+func DecodeCursor(cursor string) (any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("游标错误：%w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var last any
+	if err := decoder.Decode(&last); err != nil {
+		return nil, fmt.Errorf("游标错误：%w", err)
+	}
+	return last, nil
+}
@@ -0,0 +1,36 @@
+package page
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestReq(t *testing.T) {
+	r := Req{Page: 3, Size: 500}
+	if r.GetSize() != MaxSize {
+		t.Errorf("size should be limited to %d, got %d", MaxSize, r.GetSize())
+	}
+	if r.Offset() != 2*MaxSize {
+		t.Errorf("unexpected offset %d", r.Offset())
+	}
+	if (Req{}).GetSize() != DefaultSize || (Req{}).Offset() != 0 {
+		t.Errorf("unexpected default req")
+	}
+}
+
+func TestCursor(t *testing.T) {
+	cursor, err := EncodeCursor(int64(9007199254740993))
+	if err != nil {
+		t.Fatalf("Error encode cursor: %v", err)
+	}
+	last, err := DecodeCursor(cursor)
+	if err != nil {
+		t.Fatalf("Error decode cursor: %v", err)
+	}
+	if n, ok := last.(json.Number); !ok || n.String() != "9007199254740993" {
+		t.Errorf("unexpected cursor value %v", last)
+	}
+	if _, err := DecodeCursor("not a cursor"); err == nil {
+		t.Errorf("invalid cursor should fail")
+	}
+}
@@ -1,14 +1,24 @@
 package redis
 
 import (
+	"api-gin/infra/metrics"
+	"context"
 	"github.com/go-redis/redis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"time"
 )
 
+// RedisClient 只有经 WithContext(ctx) 发出的命令记录span，直接调用内嵌 *redis.Client 的命令不记录；
+// 耗时指标（Instrument）对两者都生效
 type RedisClient struct {
 	*redis.Client
 }
 
 type Config struct {
+	Enable   bool   `mapstructure:"enable"` // 为false时wire注入nil，不连接redis
 	Addr     string `mapstructure:"addr"`
 	Password string `mapstructure:"password"`
 	DB       int    `mapstructure:"db"`
@@ -31,3 +41,81 @@ func NewRedisClient(c Config) (*RedisClient, error) {
 		Client: r,
 	}, nil
 }
+
+// NewOptionalClient 供wire使用，未开启时返回nil；cleanup关闭连接
+func NewOptionalClient(c Config) (*RedisClient, func(), error) {
+	if !c.Enable {
+		return nil, func() {}, nil
+	}
+	r, err := NewRedisClient(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, func() {
+		_ = r.Close()
+	}, nil
+}
+
+// WithContext 返回绑定ctx的客户端，每个命令、pipeline在ctx的trace下创建span，
+// 需要链路追踪时使用：r.WithContext(ctx).Get(key)
+func (r *RedisClient) WithContext(ctx context.Context) *RedisClient {
+	// WithContext返回副本，包装只作用于副本
+	c := r.Client.WithContext(ctx)
+	c.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			_, span := startSpan(ctx, cmd.Name(), attribute.String("db.operation", cmd.Name()))
+			err := old(cmd)
+			endSpan(span, err)
+			return err
+		}
+	})
+	c.WrapProcessPipeline(func(old func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			_, span := startSpan(ctx, "pipeline", attribute.Int("db.redis.num_cmd", len(cmds)))
+			err := old(cmds)
+			endSpan(span, err)
+			return err
+		}
+	})
+	return &RedisClient{Client: c}
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("db.system", "redis"))
+	// 每次从全局获取，TracerProvider更换后生效
+	tracer := otel.Tracer("api-gin/infra/redis")
+	return tracer.Start(ctx, "redis."+name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	// redis.Nil表示key不存在，不是错误
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Instrument 记录每个命令的耗时，pipeline按pipeline统计；m未开启时不处理。
+// 需在WithContext之前调用，WithContext返回的副本会沿用
+func (r *RedisClient) Instrument(m *metrics.Metrics) {
+	if !m.Enabled() {
+		return
+	}
+	r.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			start := time.Now()
+			err := old(cmd)
+			m.RedisDuration.WithLabelValues(cmd.Name()).Observe(time.Since(start).Seconds())
+			return err
+		}
+	})
+	r.WrapProcessPipeline(func(old func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			start := time.Now()
+			err := old(cmds)
+			m.RedisDuration.WithLabelValues("pipeline").Observe(time.Since(start).Seconds())
+			return err
+		}
+	})
+}
@@ -0,0 +1,12 @@
+package redis
+
+import "testing"
+
+func TestNewOptionalClientDisabled(t *testing.T) {
+	// 未开启时不连接redis
+	r, cleanup, err := NewOptionalClient(Config{Addr: "127.0.0.1:1"})
+	if err != nil || r != nil {
+		t.Fatalf("expect nil client, got %v %v", r, err)
+	}
+	cleanup()
+}
@@ -0,0 +1,46 @@
+package response
+
+import (
+	"api-gin/infra/errcode"
+	"api-gin/infra/log"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// Response 统一的响应结构，code为0表示成功
+type Response struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data"`
+	TraceId string `json:"trace_id"`
+}
+
+const successMessage = "success"
+
+// Success 成功响应，HTTP状态码200
+func Success(ctx *gin.Context, data any) {
+	ctx.JSON(http.StatusOK, Response{
+		Code:    0,
+		Message: successMessage,
+		Data:    data,
+		TraceId: log.TraceId(ctx),
+	})
+}
+
+// Fail 失败响应，err转换为业务错误，HTTP状态码取自业务错误
+func Fail(ctx *gin.Context, err error) {
+	FailWithData(ctx, err, nil)
+}
+
+// FailWithData 失败响应并附带数据，如参数校验的详细信息
+func FailWithData(ctx *gin.Context, err error, data any) {
+	e := errcode.FromError(err)
+	// 原始错误记录到gin，供日志中间件输出
+	_ = ctx.Error(err)
+	ctx.AbortWithStatusJSON(e.Status, Response{
+		Code:    e.Code,
+		Message: e.Message,
+		Data:    data,
+		TraceId: log.TraceId(ctx),
+	})
+}
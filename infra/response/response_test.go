@@ -0,0 +1,54 @@
+package response
+
+import (
+	"api-gin/infra/errcode"
+	"api-gin/infra/log"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.ContextWithFallback = true
+	g.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), log.KeyTraceKey{}, "trace-1"))
+	})
+	g.GET("/ok", func(c *gin.Context) {
+		Success(c, gin.H{"name": "hello"})
+	})
+	g.GET("/fail", func(c *gin.Context) {
+		Fail(c, fmt.Errorf("handler: %w", errcode.ErrNotFound))
+	})
+	g.GET("/internal", func(c *gin.Context) {
+		Fail(c, errors.New("db down"))
+	})
+
+	cases := []struct {
+		path    string
+		status  int
+		code    int
+		message string
+	}{
+		{"/ok", http.StatusOK, 0, "success"},
+		{"/fail", http.StatusNotFound, errcode.ErrNotFound.Code, "数据不存在"},
+		{"/internal", http.StatusInternalServerError, errcode.ErrInternal.Code, "内部错误"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		var resp Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: invalid body %s", c.path, w.Body.String())
+		}
+		if w.Code != c.status || resp.Code != c.code || resp.Message != c.message || resp.TraceId != "trace-1" {
+			t.Errorf("%s: got %d %+v", c.path, w.Code, resp)
+		}
+	}
+}
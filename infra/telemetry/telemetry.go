@@ -0,0 +1,141 @@
+package telemetry
+
+import (
+	"api-gin/infra/log"
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+OpenTelemetry链路追踪：
+	开启后 log.StartSpan 创建OpenTelemetry的span，日志中的trace_id、span_id与导出的span一致
+	gin请求见 middleware.RequestIdMiddleware，SQL见 repo.TracingPlugin，redis见 redis.RedisClient.WithContext
+	exporter：otlp（OTLP/HTTP）、stdout、file，file/stdout 无需外部服务
+*/
+
+type Config struct {
+	Enable      bool    `mapstructure:"enable"`
+	ServiceName string  `mapstructure:"service_name"` // 为空时使用应用名
+	Exporter    string  `mapstructure:"exporter"`     // otlp, stdout, file
+	Endpoint    string  `mapstructure:"endpoint"`     // otlp的地址，如 localhost:4318
+	Insecure    bool    `mapstructure:"insecure"`     // otlp不使用https
+	File        string  `mapstructure:"file"`         // exporter为file时的输出文件
+	SampleRatio float64 `mapstructure:"sample_ratio"` // 采样比例 0-1，上游已采样时跟随上游
+}
+
+// Provider 链路追踪，未开启时 TracerProvider 为空，各处使用OpenTelemetry默认的空实现
+type Provider struct {
+	*sdktrace.TracerProvider
+}
+
+// NewProvider 创建并设置全局的TracerProvider，返回的cleanup在退出时导出剩余的span
+func NewProvider(c Config) (*Provider, func(), error) {
+	if !c.Enable {
+		return &Provider{}, func() {}, nil
+	}
+	exporter, closeFile, err := newExporter(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", c.ServiceName))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	log.SpanStarter = SpanStarter(tp.Tracer("api-gin"))
+
+	cleanup := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = tp.Shutdown(ctx)
+		closeFile()
+	}
+	return &Provider{TracerProvider: tp}, cleanup, nil
+}
+
+func newExporter(c Config) (sdktrace.SpanExporter, func(), error) {
+	noop := func() {}
+	switch c.Exporter {
+	case "otlp":
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+		if c.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(context.Background(), options...)
+		return exporter, noop, err
+	case "stdout", "":
+		exporter, err := stdouttrace.New()
+		return exporter, noop, err
+	case "file":
+		if err := os.MkdirAll(filepath.Dir(c.File), 0755); err != nil {
+			return nil, nil, err
+		}
+		f, err := os.OpenFile(c.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		return exporter, func() { _ = f.Close() }, err
+	default:
+		return nil, nil, fmt.Errorf("不支持的exporter：%s", c.Exporter)
+	}
+}
+
+// SpanStarter 以OpenTelemetry实现log.StartSpan，ctx中没有OpenTelemetry的span时为请求入口，
+// 以log.Extract解析的上游span为远程父span
+func SpanStarter(tracer trace.Tracer) func(ctx context.Context, name string) (context.Context, func()) {
+	return func(ctx context.Context, name string) (context.Context, func()) {
+		parent, hasParent := log.SpanFromContext(ctx)
+		var options []trace.SpanStartOption
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			options = append(options, trace.WithSpanKind(trace.SpanKindServer))
+			if remote, ok := remoteSpanContext(parent); hasParent && ok {
+				ctx = trace.ContextWithRemoteSpanContext(ctx, remote)
+			}
+		}
+		ctx, span := tracer.Start(ctx, name, options...)
+		sc := span.SpanContext()
+		ctx = log.ContextWithSpan(ctx, log.SpanContext{
+			TraceId:  sc.TraceID().String(),
+			SpanId:   sc.SpanID().String(),
+			ParentId: parent.SpanId,
+			Name:     name,
+			Sampled:  sc.IsSampled(),
+		})
+		return ctx, func() { span.End() }
+	}
+}
+
+func remoteSpanContext(s log.SpanContext) (trace.SpanContext, bool) {
+	traceId, err := trace.TraceIDFromHex(s.TraceId)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	spanId, err := trace.SpanIDFromHex(s.SpanId)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	var flags trace.TraceFlags
+	if s.Sampled {
+		flags = trace.FlagsSampled
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: flags,
+		Remote:     true,
+	}), true
+}
@@ -0,0 +1,101 @@
+package telemetry
+
+import (
+	"api-gin/infra/log"
+	"api-gin/infra/redis"
+	"api-gin/middleware"
+	"api-gin/repo"
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	goredis "github.com/go-redis/redis"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	starter := log.SpanStarter
+	log.SpanStarter = SpanStarter(tp.Tracer("test"))
+	defer func() { log.SpanStarter = starter }()
+
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "root:root@tcp(localhost:3306)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, SkipDefaultTransaction: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(repo.TracingPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	// 连接不存在的地址，命令会失败，但span仍会记录
+	rdb := &redis.RedisClient{Client: goredis.NewClient(&goredis.Options{Addr: "127.0.0.1:1"})}
+
+	logger, _ := log.NewLogger(log.Config{Level: "info", Format: "json"})
+	buf := new(bytes.Buffer)
+	logger.Logger.SetOutput(buf)
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.ContextWithFallback = true
+	g.Use(middleware.RequestIdMiddleware())
+	g.GET("/users/:id", func(c *gin.Context) {
+		var row map[string]any
+		db.WithContext(c).Table("user").Where("id = ?", c.Param("id")).Take(&row)
+		rdb.WithContext(c).Get("user:1")
+		logger.Info(c, "done")
+		c.Status(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	r.Header.Set(log.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	g.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := recorder.Ended()
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		byName[s.Name()] = s
+	}
+	server, query, get := byName["GET /users/:id"], byName["gorm.query"], byName["redis.get"]
+	if server == nil || query == nil || get == nil {
+		t.Fatalf("missing spans: %v", byName)
+	}
+	if server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("server span should continue upstream trace: %v", server.Parent())
+	}
+	if query.Parent().SpanID() != server.SpanContext().SpanID() || get.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("sql and redis spans should be children of the server span")
+	}
+	var statement string
+	for _, a := range query.Attributes() {
+		if a.Key == "db.statement" {
+			statement = a.Value.AsString()
+		}
+	}
+	if statement != "SELECT * FROM `user` WHERE id = ? LIMIT ?" {
+		t.Errorf("unexpected statement: %q", statement)
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["trace_id"] != server.SpanContext().TraceID().String() || entry["span_id"] != server.SpanContext().SpanID().String() {
+		t.Errorf("log should carry the active span: %v", entry)
+	}
+}
+
+func TestNewProviderDisabled(t *testing.T) {
+	p, cleanup, err := NewProvider(Config{})
+	if err != nil || p == nil || p.TracerProvider != nil {
+		t.Errorf("disabled provider: %+v, %v", p, err)
+	}
+	cleanup()
+}
@@ -0,0 +1,131 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	zhtranslations "github.com/go-playground/validator/v10/translations/zh"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+参数校验错误的本地化：
+	字段名：依次取 json、uri、form、header 标签，都没有时使用字段名
+	语言：按 Accept-Language 选择 zh、en，不支持的语言使用 zh
+*/
+
+// FieldError 单个字段的校验错误，Field为请求中的字段名，嵌套字段以.连接
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+var (
+	once    sync.Once
+	initErr error
+	uni     *ut.UniversalTranslator
+)
+
+// Init 向gin的校验器注册字段名和翻译，重复调用只执行一次
+func Init() error {
+	once.Do(func() {
+		initErr = setup()
+	})
+	return initErr
+}
+
+func setup() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("不支持的校验器：%T", binding.Validator.Engine())
+	}
+	v.RegisterTagNameFunc(fieldName)
+
+	zhLocale := zh.New()
+	uni = ut.New(zhLocale, zhLocale, en.New())
+	zhTrans, _ := uni.GetTranslator("zh")
+	if err := zhtranslations.RegisterDefaultTranslations(v, zhTrans); err != nil {
+		return err
+	}
+	enTrans, _ := uni.GetTranslator("en")
+	return entranslations.RegisterDefaultTranslations(v, enTrans)
+}
+
+// fieldName 校验错误中使用的字段名
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "uri", "form", "header"} {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// Translate 将校验错误翻译为字段错误列表，err不是校验错误时返回nil
+func Translate(err error, acceptLanguage string) []FieldError {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) || Init() != nil {
+		return nil
+	}
+	trans, _ := uni.FindTranslator(languages(acceptLanguage)...)
+	result := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		field := fe.Namespace()
+		// 去掉最外层的结构体名
+		if _, rest, ok := strings.Cut(field, "."); ok {
+			field = rest
+		}
+		result = append(result, FieldError{Field: field, Message: fe.Translate(trans)})
+	}
+	return result
+}
+
+// languages 解析Accept-Language，按q值从高到低返回，如 zh-CN -> zh_CN、zh
+func languages(header string) []string {
+	type lang struct {
+		name string
+		q    float64
+	}
+	langs := make([]lang, 0)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if name == "" || name == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		langs = append(langs, lang{name: name, q: q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+
+	result := make([]string, 0, len(langs)*2)
+	for _, l := range langs {
+		name := strings.ReplaceAll(l.name, "-", "_")
+		result = append(result, name)
+		if base, _, ok := strings.Cut(name, "_"); ok {
+			result = append(result, strings.ToLower(base))
+		} else {
+			result = append(result, strings.ToLower(name))
+		}
+	}
+	return result
+}
@@ -0,0 +1,58 @@
+package validate
+
+import (
+	"github.com/gin-gonic/gin/binding"
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" binding:"required"`
+}
+
+type createReq struct {
+	ID      int64   `uri:"id" binding:"required"`
+	Name    string  `json:"name" binding:"max=3"`
+	Address address `json:"address"`
+}
+
+func TestTranslate(t *testing.T) {
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	err := binding.Validator.ValidateStruct(&createReq{Name: "toolong"})
+	if err == nil {
+		t.Fatal("validation should fail")
+	}
+
+	zh := Translate(err, "zh-CN,zh;q=0.9,en;q=0.8")
+	want := []FieldError{
+		{"id", "id为必填字段"},
+		{"name", "name长度不能超过3个字符"},
+		{"address.city", "city为必填字段"},
+	}
+	if !reflect.DeepEqual(zh, want) {
+		t.Errorf("zh: got %+v", zh)
+	}
+
+	en := Translate(err, "fr;q=0.9, en-US;q=0.8")
+	if len(en) != 3 || en[0].Message != "id is a required field" {
+		t.Errorf("en: got %+v", en)
+	}
+
+	// 未指定语言时使用zh
+	if def := Translate(err, ""); def[0].Message != want[0].Message {
+		t.Errorf("default: got %+v", def)
+	}
+	if Translate(nil, "en") != nil {
+		t.Errorf("non-validation error should return nil")
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	got := languages("en;q=0.5, zh-CN")
+	want := []string{"zh_CN", "zh", "en", "en"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"api-gin/infra/log"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// LoggerMiddlerware 访问日志：方法、路由模板、状态码、耗时、响应字节数、客户端IP
+func LoggerMiddlerware(logger *log.Logger) gin.HandlerFunc {
+	logger = logger.NewLogger("access")
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := logrus.Fields{
+			"method":     c.Request.Method,
+			"route":      c.FullPath(),
+			"path":       c.Request.URL.Path,
+			"status":     status,
+			"latency_ms": time.Since(start).Milliseconds(),
+			"bytes":      max(c.Writer.Size(), 0),
+			"client_ip":  c.ClientIP(),
+		}
+		if len(c.Errors) > 0 {
+			fields["errors"] = c.Errors.String()
+		}
+		level := logrus.InfoLevel
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = logrus.ErrorLevel
+		case status >= http.StatusBadRequest:
+			level = logrus.WarnLevel
+		}
+		logger.LogFields(c, level, fields, "access")
+	}
+}
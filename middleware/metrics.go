@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"api-gin/infra/metrics"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"time"
+)
+
+// MetricsMiddleware 记录请求数和耗时，按路由模板统计，未匹配的路由合并为unmatched，避免标签过多
+func MetricsMiddleware(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		m.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		m.HTTPDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
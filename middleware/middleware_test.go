@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"api-gin/infra/log"
+	"api-gin/infra/metrics"
+	"api-gin/repo"
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestEngine(t *testing.T) (*gin.Engine, *bytes.Buffer) {
+	logger, err := log.NewLogger(log.Config{Level: "info", Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	logger.Logger.SetOutput(buf)
+
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.ContextWithFallback = true
+	g.Use(RequestIdMiddleware(), LoggerMiddlerware(logger), RecoveryMiddlerware(logger))
+	g.GET("/items/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	g.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return g, buf
+}
+
+// entries 解析json格式的日志
+func entries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	result := make([]map[string]any, 0)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q", line)
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+func TestAccessLog(t *testing.T) {
+	g, buf := newTestEngine(t)
+	r := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	r.Header.Set(HeaderRequestId, "req-1")
+	r.Header.Set(log.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	if w.Header().Get(HeaderRequestId) != "req-1" {
+		t.Errorf("request id not echoed: %q", w.Header().Get(HeaderRequestId))
+	}
+	span, ok := log.ParseTraceparent(w.Header().Get(log.HeaderTraceparent))
+	if !ok || span.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || span.SpanId == "00f067aa0ba902b7" {
+		t.Errorf("unexpected traceparent: %q", w.Header().Get(log.HeaderTraceparent))
+	}
+	logs := entries(t, buf)
+	if len(logs) != 1 {
+		t.Fatalf("unexpected logs: %s", buf.String())
+	}
+	entry := logs[0]
+	if entry["route"] != "/items/:id" || entry["status"] != float64(200) || entry["bytes"] != float64(2) ||
+		entry["trace_id"] != span.TraceId || entry["span_id"] != span.SpanId || entry["parent_span_id"] != "00f067aa0ba902b7" || entry["method"] != "GET" || entry["level"] != "info" {
+		t.Errorf("unexpected access log: %v", entry)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	g, buf := newTestEngine(t)
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", w.Code)
+	}
+	var body struct {
+		Code    int    `json:"code"`
+		TraceId string `json:"trace_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code == 0 || body.TraceId == "" || body.TraceId != w.Header().Get(HeaderRequestId) {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+	logs := entries(t, buf)
+	if len(logs) != 2 || !strings.Contains(logs[0]["msg"].(string), "panic: boom") || logs[1]["status"] != float64(500) {
+		t.Errorf("unexpected logs: %s", buf.String())
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	m, err := metrics.NewMetrics(metrics.Config{Enable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(MetricsMiddleware(m))
+	g.GET("/items/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	for _, path := range []string{"/items/1", "/items/2", "/missing"} {
+		g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	// 按路由模板合并
+	if v := testutil.ToFloat64(m.HTTPRequests.WithLabelValues("GET", "/items/:id", "200")); v != 2 {
+		t.Errorf("expect 2 requests, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.HTTPRequests.WithLabelValues("GET", "unmatched", "404")); v != 1 {
+		t.Errorf("expect 1 unmatched request, got %v", v)
+	}
+}
+
+func TestReadYourWritesMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(ReadYourWritesMiddleware())
+	g.GET("/", func(c *gin.Context) {
+		if c.Request.Context().Value(repo.KeyReadYourWrites{}) == nil {
+			t.Errorf("read your writes not enabled")
+		}
+	})
+	g.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
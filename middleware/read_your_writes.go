@@ -0,0 +1,14 @@
+package middleware
+
+import (
+	"api-gin/repo"
+	"github.com/gin-gonic/gin"
+)
+
+// ReadYourWritesMiddleware 读写一致：请求内写入后，后续读操作走主库
+func ReadYourWritesMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(repo.WithReadYourWrites(c.Request.Context()))
+		c.Next()
+	}
+}
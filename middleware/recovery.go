@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"api-gin/infra/errcode"
+	"api-gin/infra/log"
+	"api-gin/infra/response"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net"
+	"os"
+	"runtime/debug"
+	"syscall"
+)
+
+// RecoveryMiddlerware 捕获handler的panic，记录堆栈并返回统一的内部错误
+func RecoveryMiddlerware(logger *log.Logger) gin.HandlerFunc {
+	logger = logger.NewLogger("recovery")
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			// 客户端已断开，无法再写入响应
+			if isBrokenPipe(err) {
+				logger.Warnf(c, "connection broken: %v", err)
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
+			logger.Errorf(c, "panic: %v\n%s", r, debug.Stack())
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			response.Fail(c, errcode.ErrInternal.Wrap(err))
+		}()
+		c.Next()
+	}
+}
+
+func isBrokenPipe(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var sysErr *os.SyscallError
+	return errors.As(opErr, &sysErr) && (errors.Is(sysErr.Err, syscall.EPIPE) || errors.Is(sysErr.Err, syscall.ECONNRESET))
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"api-gin/infra/log"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// HeaderRequestId 请求ID的响应头，上游传入时原样返回，否则为traceId
+const HeaderRequestId = "X-Request-Id"
+
+// RequestIdMiddleware 按上游的traceparent创建本次请求的span并写入请求ctx，
+// 日志与响应中的trace_id使用同一个traceId，响应头返回traceparent供调用方关联
+func RequestIdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Request.Method
+		if route := c.FullPath(); route != "" {
+			name += " " + route
+		}
+		ctx, end := log.StartSpan(log.Extract(c.Request.Context(), c.Request.Header), name)
+		defer end()
+		c.Request = c.Request.WithContext(ctx)
+
+		id := c.GetHeader(HeaderRequestId)
+		if id == "" || len(id) > 128 {
+			id = log.TraceId(ctx)
+		}
+		c.Header(HeaderRequestId, id)
+		log.Inject(ctx, c.Writer.Header())
+		c.Next()
+
+		// 开启OpenTelemetry时记录到span，未开启时为空实现
+		span := trace.SpanFromContext(ctx)
+		status := c.Writer.Status()
+		span.SetAttributes(
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", c.FullPath()),
+			attribute.Int("http.response.status_code", status),
+			attribute.String("client.address", c.ClientIP()),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
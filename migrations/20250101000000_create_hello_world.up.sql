@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `hello_world` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) DEFAULT NULL,
+  `create_time` datetime DEFAULT NULL,
+  `age` int(11) DEFAULT NULL,
+  `mystruct` varchar(255) DEFAULT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
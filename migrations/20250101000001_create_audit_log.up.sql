@@ -0,0 +1,15 @@
+-- 变更审计，见 repo.AuditRecord
+CREATE TABLE IF NOT EXISTS `audit_log` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `table_name` varchar(64) NOT NULL,
+  `action` varchar(16) NOT NULL,
+  `primary_key` varchar(255) NOT NULL,
+  `before_data` text,
+  `after_data` text,
+  `operator` varchar(64) NOT NULL DEFAULT '',
+  `trace_id` varchar(64) NOT NULL DEFAULT '',
+  `create_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_table_pk` (`table_name`, `primary_key`),
+  KEY `idx_trace_id` (`trace_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,8 @@
+package migrations
+
+import "embed"
+
+// FS 内置的数据库迁移，文件名格式：<版本>_<名称>.up.sql、<版本>_<名称>.down.sql
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,325 @@
+package repo
+
+import (
+	"api-gin/infra/log"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// Auditable model实现该接口即开启变更审计
+type Auditable interface {
+	Auditable()
+}
+
+// KeyOperator 操作人
+type KeyOperator struct{}
+
+// WithOperator 设置操作人，写入审计记录
+func WithOperator(ctx context.Context, operator string) context.Context {
+	return context.WithValue(ctx, KeyOperator{}, operator)
+}
+
+type AuditConfig struct {
+	Enable bool   `mapstructure:"enable"`
+	Table  string `mapstructure:"table"` // 审计表，默认audit_log
+}
+
+// AuditRecord 一行数据的一次变更
+type AuditRecord struct {
+	ID         int64     `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Table      string    `gorm:"column:table_name" json:"table_name"`
+	Action     string    `gorm:"column:action" json:"action"` // create, update, delete
+	PrimaryKey string    `gorm:"column:primary_key" json:"primary_key"`
+	Before     string    `gorm:"column:before_data" json:"before_data"` // 变更前的行，JSON
+	After      string    `gorm:"column:after_data" json:"after_data"`   // 变更后的行，JSON
+	Operator   string    `gorm:"column:operator" json:"operator"`
+	TraceId    string    `gorm:"column:trace_id" json:"trace_id"`
+	CreateTime time.Time `gorm:"column:create_time" json:"create_time"`
+}
+
+// AuditSink 审计记录的写入方式，db为触发变更的会话，与业务SQL在同一事务中
+type AuditSink interface {
+	Write(db *gorm.DB, records []AuditRecord) error
+}
+
+// TableSink 写入审计表，与业务SQL同一事务，随业务回滚
+type TableSink struct {
+	Table string
+}
+
+func (s TableSink) Write(db *gorm.DB, records []AuditRecord) error {
+	return db.Table(s.Table).Create(&records).Error
+}
+
+// AsyncSink 异步写入，Handler在后台执行，缓冲区满时丢弃并交给OnError
+type AsyncSink struct {
+	ch      chan []AuditRecord
+	OnError func(err error)
+}
+
+func NewAsyncSink(buffer int, handler func(records []AuditRecord) error, onError func(err error)) *AsyncSink {
+	s := &AsyncSink{
+		ch:      make(chan []AuditRecord, buffer),
+		OnError: onError,
+	}
+	go func() {
+		for records := range s.ch {
+			if err := handler(records); err != nil && s.OnError != nil {
+				s.OnError(err)
+			}
+		}
+	}()
+	return s
+}
+
+func (s *AsyncSink) Write(db *gorm.DB, records []AuditRecord) error {
+	select {
+	case s.ch <- records:
+	default:
+		if s.OnError != nil {
+			s.OnError(fmt.Errorf("审计缓冲区已满，丢弃%d条记录", len(records)))
+		}
+	}
+	return nil
+}
+
+// Close 停止接收，已缓冲的记录处理完后后台退出
+func (s *AsyncSink) Close() {
+	close(s.ch)
+}
+
+const auditBeforeKey = "repo:audit_before"
+
+// AuditPlugin gorm插件，记录 Auditable model 新增、更新、删除前后的行
+type AuditPlugin struct {
+	sink AuditSink
+}
+
+func NewAuditPlugin(sink AuditSink) *AuditPlugin {
+	return &AuditPlugin{sink: sink}
+}
+
+func (p *AuditPlugin) Name() string {
+	return "repo:audit"
+}
+
+func (p *AuditPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Update().Before("gorm:update").Register("repo:audit_before", p.before); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("repo:audit_before", p.before); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("repo:audit_after", p.after("create")); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("repo:audit_after", p.after("update")); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("repo:audit_after", p.after("delete"))
+}
+
+// auditable 是否需要审计
+func auditable(db *gorm.DB) bool {
+	stmt := db.Statement
+	if db.Error != nil || db.DryRun || stmt.Schema == nil || len(stmt.Schema.PrimaryFields) == 0 {
+		return false
+	}
+	_, ok := reflect.New(stmt.Schema.ModelType).Interface().(Auditable)
+	return ok
+}
+
+// before 更新、删除前查询将要变更的行
+func (p *AuditPlugin) before(db *gorm.DB) {
+	if !auditable(db) {
+		return
+	}
+	q := p.session(db).Table(db.Statement.Table)
+	conditions := 0
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			q = q.Clauses(where)
+			conditions++
+		}
+	}
+	// model中有主键时，gorm会以主键为条件
+	for _, field := range db.Statement.Schema.PrimaryFields {
+		if v, zero := field.ValueOf(db.Statement.Context, db.Statement.ReflectValue); !zero && db.Statement.ReflectValue.Kind() == reflect.Struct {
+			q = q.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: v})
+			conditions++
+		}
+	}
+	// 没有条件时为全表更新、删除（AllowGlobalUpdate），不将整表读入内存，也不做审计
+	if conditions == 0 {
+		return
+	}
+	rows := make([]map[string]any, 0)
+	if err := q.Find(&rows).Error; err != nil {
+		_ = db.AddError(fmt.Errorf("审计查询变更前数据失败：%w", err))
+		return
+	}
+	db.InstanceSet(auditBeforeKey, rows)
+}
+
+// after 变更后按主键查询变更后的行并写入审计
+func (p *AuditPlugin) after(action string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if !auditable(db) {
+			return
+		}
+		stmt := db.Statement
+		pks := stmt.Schema.PrimaryFieldDBNames
+
+		var before []map[string]any
+		if v, ok := db.InstanceGet(auditBeforeKey); ok {
+			before, _ = v.([]map[string]any)
+		}
+		keys := make([][]any, 0)
+		if action == "create" {
+			keys = createdKeys(stmt)
+		} else {
+			for _, row := range before {
+				key := make([]any, 0, len(pks))
+				for _, pk := range pks {
+					key = append(key, row[pk])
+				}
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			return
+		}
+
+		after := make([]map[string]any, 0)
+		if action != "delete" {
+			q := p.session(db).Table(stmt.Table).Where(primaryIn(pks, keys))
+			if err := q.Find(&after).Error; err != nil {
+				_ = db.AddError(fmt.Errorf("审计查询变更后数据失败：%w", err))
+				return
+			}
+		}
+
+		ctx := stmt.Context
+		operator, _ := ctx.Value(KeyOperator{}).(string)
+		traceId := log.TraceId(ctx)
+		now := time.Now()
+		beforeMap, afterMap := indexRows(before, pks), indexRows(after, pks)
+		records := make([]AuditRecord, 0, len(keys))
+		for _, key := range keys {
+			k := formatKey(key)
+			records = append(records, AuditRecord{
+				Table:      stmt.Table,
+				Action:     action,
+				PrimaryKey: k,
+				Before:     marshalRow(beforeMap[k]),
+				After:      marshalRow(afterMap[k]),
+				Operator:   operator,
+				TraceId:    traceId,
+				CreateTime: now,
+			})
+		}
+		if err := p.sink.Write(p.session(db), records); err != nil {
+			_ = db.AddError(fmt.Errorf("写入审计失败：%w", err))
+		}
+	}
+}
+
+// session 复用当前连接（含事务）的新会话，不触发钩子。
+// 读写分离时走主库，避免从库延迟读到变更前的数据
+func (p *AuditPlugin) session(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Clauses(dbresolver.Write)
+}
+
+// createdKeys 新增后model中的主键，支持批量新增
+func createdKeys(stmt *gorm.Statement) [][]any {
+	keys := make([][]any, 0)
+	collect := func(rv reflect.Value) {
+		key := make([]any, 0, len(stmt.Schema.PrimaryFields))
+		for _, field := range stmt.Schema.PrimaryFields {
+			v, _ := field.ValueOf(stmt.Context, rv)
+			key = append(key, v)
+		}
+		keys = append(keys, key)
+	}
+	rv := reflect.Indirect(stmt.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			collect(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		collect(rv)
+	}
+	return keys
+}
+
+// primaryIn 主键 IN 条件，支持联合主键
+func primaryIn(pks []string, keys [][]any) clause.Expression {
+	columns := make([]clause.Column, 0, len(pks))
+	for _, pk := range pks {
+		columns = append(columns, clause.Column{Table: clause.CurrentTable, Name: pk})
+	}
+	if len(pks) == 1 {
+		values := make([]any, 0, len(keys))
+		for _, key := range keys {
+			values = append(values, key[0])
+		}
+		return clause.IN{Column: columns[0], Values: values}
+	}
+	values := make([]any, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, key)
+	}
+	return clause.IN{Column: columns, Values: values}
+}
+
+func indexRows(rows []map[string]any, pks []string) map[string]map[string]any {
+	m := make(map[string]map[string]any, len(rows))
+	for _, row := range rows {
+		key := make([]any, 0, len(pks))
+		for _, pk := range pks {
+			key = append(key, row[pk])
+		}
+		m[formatKey(key)] = row
+	}
+	return m
+}
+
+func formatKey(key []any) string {
+	parts := make([]string, 0, len(key))
+	for _, v := range key {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return strings.Join(parts, ",")
+}
+
+func marshalRow(row map[string]any) string {
+	if row == nil {
+		return ""
+	}
+	for k, v := range row {
+		// sql.NullString 等取其数据库值
+		if valuer, ok := v.(driver.Valuer); ok {
+			v, _ = valuer.Value()
+		}
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		row[k] = v
+	}
+	data, _ := json.Marshal(row)
+	return string(data)
+}
@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+type testAudited struct {
+	ID   int64  `gorm:"column:id;primaryKey"`
+	Name string `gorm:"column:name"`
+}
+
+func (a *testAudited) TableName() string {
+	return "test_audited"
+}
+
+func (a *testAudited) Auditable() {}
+
+func TestAuditPlugin(t *testing.T) {
+	db := newDryRunDB(t)
+	if err := db.Use(NewAuditPlugin(TableSink{Table: "audit_log"})); err != nil {
+		t.Fatalf("Error use audit plugin: %v", err)
+	}
+	rec := recordSQL(db)
+	r := NewRepo[testAudited](db)
+	if err := r.Update(context.Background(), &testAudited{ID: 1, Name: "tom"}); err != nil {
+		t.Fatalf("Error update: %v", err)
+	}
+	// dry run 不做审计，只执行业务SQL
+	if want := "UPDATE `test_audited` SET `name`='tom' WHERE `id` = 1"; rec.sql != want {
+		t.Errorf("got sql %q, want %q", rec.sql, want)
+	}
+}
+
+// memorySink 记录写入的审计
+type memorySink struct {
+	records []AuditRecord
+}
+
+func (s *memorySink) Write(db *gorm.DB, records []AuditRecord) error {
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// newAuditTestDB 不连接数据库，查询、更新在内存中的一行上执行，queries为查询次数
+func newAuditTestDB(t *testing.T) (*gorm.DB, *memorySink, *int) {
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "root:root@tcp(localhost:3306)/openapi",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{SkipDefaultTransaction: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := map[string]any{"id": int64(1), "name": "tom"}
+	queries := 0
+	cb := db.Callback()
+	if err := cb.Query().Replace("gorm:query", func(db *gorm.DB) {
+		queries++
+		copied := make(map[string]any, len(row))
+		for k, v := range row {
+			copied[k] = v
+		}
+		dest := db.Statement.Dest.(*[]map[string]any)
+		*dest = append(*dest, copied)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.Update().Replace("gorm:update", func(db *gorm.DB) {
+		if m, ok := db.Statement.Dest.(*testAudited); ok {
+			row["name"] = m.Name
+		}
+		db.RowsAffected = 1
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sink := &memorySink{}
+	if err := db.Use(NewAuditPlugin(sink)); err != nil {
+		t.Fatal(err)
+	}
+	return db, sink, &queries
+}
+
+func TestAuditCapture(t *testing.T) {
+	db, sink, _ := newAuditTestDB(t)
+	ctx := WithOperator(context.Background(), "admin")
+	r := NewRepo[testAudited](db)
+	if err := r.Update(ctx, &testAudited{ID: 1, Name: "jerry"}); err != nil {
+		t.Fatalf("Error update: %v", err)
+	}
+	if len(sink.records) != 1 {
+		t.Fatalf("expect 1 record, got %+v", sink.records)
+	}
+	record := sink.records[0]
+	if record.Table != "test_audited" || record.Action != "update" || record.PrimaryKey != "1" || record.Operator != "admin" {
+		t.Errorf("unexpected record: %+v", record)
+	}
+	var before, after map[string]any
+	_ = json.Unmarshal([]byte(record.Before), &before)
+	_ = json.Unmarshal([]byte(record.After), &after)
+	if before["name"] != "tom" || after["name"] != "jerry" {
+		t.Errorf("unexpected before %s, after %s", record.Before, record.After)
+	}
+}
+
+func TestAuditGlobalUpdate(t *testing.T) {
+	db, sink, queries := newAuditTestDB(t)
+	err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&testAudited{}).Update("name", "all").Error
+	if err != nil {
+		t.Fatalf("Error update: %v", err)
+	}
+	// 没有条件时不读取整表
+	if *queries != 0 || len(sink.records) != 0 {
+		t.Errorf("expect no capture, got %d queries, records %+v", *queries, sink.records)
+	}
+}
+
+func TestAuditReadFromSource(t *testing.T) {
+	// 主库、从库各一个连接池，读取每行时计数
+	var sourceRows, replicaRows int
+	source := sql.OpenDB(&fakeRowsDriver{next: func() { sourceRows++ }})
+	replica := sql.OpenDB(&fakeRowsDriver{next: func() { replicaRows++ }})
+	dialector := func(pool *sql.DB) gorm.Dialector {
+		return mysql.New(mysql.Config{Conn: pool, SkipInitializeWithVersion: true})
+	}
+	db, err := gorm.Open(dialector(source), &gorm.Config{SkipDefaultTransaction: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(dbresolver.Register(dbresolver.Config{
+		Sources:  []gorm.Dialector{dialector(source)},
+		Replicas: []gorm.Dialector{dialector(replica)},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	sink := &memorySink{}
+	if err := db.Use(NewAuditPlugin(sink)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRepo[testAudited](db)
+	if err := r.Update(context.Background(), &testAudited{ID: 1, Name: "jerry"}); err != nil {
+		t.Fatalf("Error update: %v", err)
+	}
+	// 变更前后的数据都从主库读取
+	if replicaRows != 0 || sourceRows == 0 || len(sink.records) == 0 {
+		t.Errorf("audit should read from source, got source %d, replica %d, records %d", sourceRows, replicaRows, len(sink.records))
+	}
+}
+
+func TestAuditKeys(t *testing.T) {
+	rows := []map[string]any{
+		{"id": int64(1), "name": []byte("tom")},
+		{"id": int64(2), "name": "jerry"},
+	}
+	index := indexRows(rows, []string{"id"})
+	if row, ok := index[formatKey([]any{int64(1)})]; !ok || marshalRow(row) != `{"id":1,"name":"tom"}` {
+		t.Errorf("unexpected row %v", row)
+	}
+	if formatKey([]any{1, "a"}) != "1,a" {
+		t.Errorf("unexpected key %s", formatKey([]any{1, "a"}))
+	}
+}
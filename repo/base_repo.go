@@ -2,9 +2,11 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gorm.io/plugin/dbresolver"
 	"strconv"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -15,22 +17,53 @@ type IBaseRepo interface {
 	Write(ctx context.Context) *gorm.DB
 	// Read 指定读库
 	Read(ctx context.Context) *gorm.DB
-	// StartTrans 外部调用，启动事务，存入ctx
+	// StartTrans 外部调用，启动事务，存入ctx；ctx中已有事务时创建保存点
 	StartTrans(ctx context.Context, tx *gorm.DB) context.Context
+	// CommitTrans 提交ctx中的事务，保存点则释放
+	CommitTrans(ctx context.Context) error
+	// RollbackTrans 回滚ctx中的事务，保存点则只回滚到保存点
+	RollbackTrans(ctx context.Context) error
 	// GetDB 每次查询前调用，检查ctx，返回DB，防止启用了事务
 	GetDB(ctx context.Context) *gorm.DB
+	// Transaction 托管事务，fn返回nil时提交，返回error或panic时回滚；ctx中已有事务时以保存点嵌套
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// SetCluster 设置分库，参数为分库依据，按 Clusters 的路由规则选择
+	SetCluster(ctx context.Context, key any) (context.Context, error)
 	// SetSuffix 设置分表后缀，参数依据对应的分表实现
 	SetSuffix(ctx context.Context, params ...any) (context.Context, error)
 	// GetTableName 每次查询前调用，检查ctx，返回表名，防止设置了分表
 	GetTableName(ctx context.Context) string
+	// ResolveTableName 同GetTableName，分表未设置后缀时返回错误
+	ResolveTableName(ctx context.Context) (string, error)
+	// Query 返回已绑定数据源与表名的DB：有事务用事务，否则按mode选择主库或从库
+	Query(ctx context.Context, mode DBMode) (*gorm.DB, error)
 }
 
+// DBMode 查询的数据源
+type DBMode int
+
+const (
+	ModeRead  DBMode = iota // 从库
+	ModeWrite               // 主库
+)
+
+// ErrNoShardSuffix 分表未设置后缀，需先调用SetSuffix
+var ErrNoShardSuffix = errors.New("分表未设置后缀")
+
 type BaseRepo struct {
 	Db *gorm.DB
 
-	TableName string
-	ShardType ShardType
-	ShardFunc *ShardTool
+	TableName  string
+	ShardType  ShardType
+	ShardFunc  *ShardTool
+	Strategy   ShardStrategy // ShardTypeStrategy 使用的分表策略
+	Clusters   *Clusters     // 分库，为空时只使用Db
+	AutoCreate bool          // 写入前自动创建缺失的分表
+	Retention  int           // 分表保留的周期数，0为不清理
+	Timestamps bool          // 自动填充create_time、update_time
+	SoftDelete bool          // 软删除，查询过滤deleted_at不为空的数据
+	Versioned  bool          // 更新时以version做乐观锁
+	created    sync.Map      // 已确认存在的分表
 }
 
 // NewBaseRepo 创建一个基础DB，不参与wire
@@ -45,6 +78,9 @@ func NewBaseRepo(db *gorm.DB, options ...Option) *BaseRepo {
 	if baseDB.ShardFunc == nil {
 		baseDB.ShardFunc = &defaultShardTool
 	}
+	if baseDB.ShardType != ShardNone {
+		registerShardRepo(baseDB)
+	}
 	return baseDB
 }
 
@@ -59,6 +95,15 @@ func WithTableName(table string) Option {
 // KeyTransDb 事务
 type KeyTransDb struct{}
 
+// KeySavePoint 嵌套事务的保存点
+type KeySavePoint struct{}
+
+type savePoint struct {
+	name  string
+	depth int
+	err   error // 创建保存点失败
+}
+
 // KeyTableSuffix 分表后缀
 type KeyTableSuffix struct{}
 
@@ -71,6 +116,7 @@ const (
 	ShardTypeWeek
 	ShardTypeMonth
 	ShardTypeMod
+	ShardTypeStrategy // 自定义策略，见 ShardStrategy
 )
 
 func WithShard(shard bool, t ShardType) Option {
@@ -118,27 +164,161 @@ func WithShardFunc(tool *ShardTool) Option {
 }
 
 func (b *BaseRepo) Write(ctx context.Context) *gorm.DB {
-	return b.Db.WithContext(ctx).Clauses(dbresolver.Write)
+	return b.db(ctx).WithContext(ctx).Clauses(dbresolver.Write)
 }
 
 func (b *BaseRepo) Read(ctx context.Context) *gorm.DB {
-	return b.Db.WithContext(ctx).Clauses(dbresolver.Read)
+	db := b.db(ctx)
+	if b.readFromMaster(ctx, db) {
+		return db.WithContext(ctx).Clauses(dbresolver.Write)
+	}
+	return db.WithContext(ctx).Clauses(dbresolver.Read)
+}
+
+// db 开启分库时返回ctx对应的分库，未设置分库时返回带有ErrNoCluster的DB，执行时返回该错误
+func (b *BaseRepo) db(ctx context.Context) *gorm.DB {
+	db, err := b.clusterDB(ctx)
+	if err != nil {
+		return b.errDB(err)
+	}
+	return db
+}
+
+// errDB 返回带有err的DB，之后的链式调用、执行均返回err
+func (b *BaseRepo) errDB(err error) *gorm.DB {
+	db := b.Db
+	if b.Clusters != nil && len(b.Clusters.names) > 0 {
+		db, _ = b.Clusters.Get(b.Clusters.names[0])
+	}
+	tx := db.Session(&gorm.Session{NewDB: true})
+	_ = tx.AddError(err)
+	return tx
 }
 
 func (b *BaseRepo) StartTrans(ctx context.Context, tx *gorm.DB) context.Context {
-	if tx == nil {
-		tx = b.Write(ctx).Begin()
+	if tx != nil {
+		ctx = context.WithValue(ctx, KeySavePoint{}, (*savePoint)(nil))
+		ctx = context.WithValue(ctx, KeyTransCluster{}, b.transCluster(ctx))
+		return context.WithValue(ctx, KeyTransDb{}, tx)
 	}
-	return context.WithValue(ctx, KeyTransDb{}, tx)
+	// 已有事务，在外层事务上创建保存点
+	if outer, ok := getTransDb(ctx); ok {
+		sp := &savePoint{depth: 1}
+		if parent := getSavePoint(ctx); parent != nil {
+			sp.depth = parent.depth + 1
+		}
+		sp.name = fmt.Sprintf("sp_%d", sp.depth)
+		if sp.err = b.checkTransCluster(ctx); sp.err == nil {
+			sp.err = outer.SavePoint(sp.name).Error
+		}
+		return context.WithValue(ctx, KeySavePoint{}, sp)
+	}
+	ctx = context.WithValue(ctx, KeySavePoint{}, (*savePoint)(nil))
+	ctx = context.WithValue(ctx, KeyTransCluster{}, b.transCluster(ctx))
+	db, err := b.clusterDB(ctx)
+	if err != nil {
+		// 未设置分库时不开启事务，提交、回滚返回该错误
+		return context.WithValue(ctx, KeyTransDb{}, b.errDB(err))
+	}
+	return context.WithValue(ctx, KeyTransDb{}, db.WithContext(ctx).Clauses(dbresolver.Write).Begin())
+}
+
+func (b *BaseRepo) CommitTrans(ctx context.Context) error {
+	tx, ok := getTransDb(ctx)
+	if !ok {
+		return fmt.Errorf("未开启事务")
+	}
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if sp := getSavePoint(ctx); sp != nil {
+		if sp.err != nil {
+			return sp.err
+		}
+		return tx.Exec("RELEASE SAVEPOINT " + sp.name).Error
+	}
+	return tx.Commit().Error
+}
+
+func (b *BaseRepo) RollbackTrans(ctx context.Context) error {
+	tx, ok := getTransDb(ctx)
+	if !ok {
+		return fmt.Errorf("未开启事务")
+	}
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if sp := getSavePoint(ctx); sp != nil {
+		if sp.err != nil {
+			return sp.err
+		}
+		return tx.RollbackTo(sp.name).Error
+	}
+	return tx.Rollback().Error
 }
 
 func (b *BaseRepo) GetDB(ctx context.Context) *gorm.DB {
+	if tx, ok := getTransDb(ctx); ok {
+		if err := b.checkTransCluster(ctx); err != nil {
+			return b.errDB(err)
+		}
+		return tx
+	}
+	return b.db(ctx).WithContext(ctx)
+}
+
+func (b *BaseRepo) Transaction(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if _, ok := getTransDb(ctx); !ok {
+		db, err := b.clusterDB(ctx)
+		if err != nil {
+			return err
+		}
+		// gorm.Transaction 会在 error 或 panic 时回滚，panic 继续向上抛出
+		return db.WithContext(ctx).Clauses(dbresolver.Write).Transaction(func(tx *gorm.DB) error {
+			return fn(b.StartTrans(ctx, tx))
+		})
+	}
+	if err := b.checkTransCluster(ctx); err != nil {
+		return err
+	}
+
+	// 已有事务，使用保存点，失败时只回滚内层
+	ctx = b.StartTrans(ctx, nil)
+	// 保存点未创建成功时不执行fn，否则其写入无法单独回滚
+	if sp := getSavePoint(ctx); sp != nil && sp.err != nil {
+		return sp.err
+	}
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			if rbErr := b.RollbackTrans(ctx); rbErr != nil && err != nil {
+				err = fmt.Errorf("%w; 回滚保存点失败: %v", err, rbErr)
+			}
+		}
+	}()
+	if err = fn(ctx); err == nil {
+		err = b.CommitTrans(ctx)
+	}
+	panicked = false
+	return err
+}
+
+// getTransDb 从ctx中取出事务
+func getTransDb(ctx context.Context) (*gorm.DB, bool) {
 	if txAny := ctx.Value(KeyTransDb{}); txAny != nil {
 		if tx, ok := txAny.(*gorm.DB); ok {
-			return tx
+			return tx, true
 		}
 	}
-	return b.Db.WithContext(ctx)
+	return nil, false
+}
+
+// getSavePoint 从ctx中取出当前保存点，nil表示最外层事务
+func getSavePoint(ctx context.Context) *savePoint {
+	if sp, ok := ctx.Value(KeySavePoint{}).(*savePoint); ok {
+		return sp
+	}
+	return nil
 }
 
 func (b *BaseRepo) SetSuffix(ctx context.Context, params ...any) (context.Context, error) {
@@ -149,9 +329,16 @@ func (b *BaseRepo) SetSuffix(ctx context.Context, params ...any) (context.Contex
 		if len(params) == 0 {
 			return nil, fmt.Errorf("请提供时间依据")
 		}
-		if t, ok := params[0].(time.Time); !ok {
+		t, ok := params[0].(time.Time)
+		if !ok {
 			return nil, fmt.Errorf("请提供正确时间")
-		} else {
+		}
+		switch b.ShardType {
+		case ShardTypeWeek:
+			return b.ShardFunc.ShardTypeWeek(ctx, t), nil
+		case ShardTypeMonth:
+			return b.ShardFunc.ShardTypeMonth(ctx, t), nil
+		default:
 			return b.ShardFunc.ShardTypeDay(ctx, t), nil
 		}
 	case ShardTypeMod:
@@ -164,6 +351,18 @@ func (b *BaseRepo) SetSuffix(ctx context.Context, params ...any) (context.Contex
 			return nil, fmt.Errorf("请提供正确取模参数")
 		}
 		return b.ShardFunc.ShardTypeMod(ctx, now, modBase), nil
+	case ShardTypeStrategy:
+		if len(params) == 0 {
+			return nil, fmt.Errorf("请提供分表依据")
+		}
+		if b.Strategy == nil {
+			return nil, fmt.Errorf("未设置分表策略")
+		}
+		suffix, err := b.Strategy.Suffix(params[0])
+		if err != nil {
+			return nil, err
+		}
+		return context.WithValue(ctx, KeyTableSuffix{}, suffix), nil
 	default:
 		return nil, fmt.Errorf("分表类型错误")
 	}
@@ -175,3 +374,47 @@ func (b *BaseRepo) GetTableName(ctx context.Context) string {
 	}
 	return fmt.Sprintf("%s_%s", b.TableName, ctx.Value(KeyTableSuffix{}))
 }
+
+func (b *BaseRepo) ResolveTableName(ctx context.Context) (string, error) {
+	if b.ShardType == ShardNone {
+		return b.TableName, nil
+	}
+	suffix, _ := ctx.Value(KeyTableSuffix{}).(string)
+	if suffix == "" {
+		return "", fmt.Errorf("%w: %s", ErrNoShardSuffix, b.TableName)
+	}
+	return fmt.Sprintf("%s_%s", b.TableName, suffix), nil
+}
+
+func (b *BaseRepo) Query(ctx context.Context, mode DBMode) (*gorm.DB, error) {
+	table, err := b.ResolveTableName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if mode == ModeWrite && b.AutoCreate {
+		if err := b.EnsureTable(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var db *gorm.DB
+	if tx, ok := getTransDb(ctx); ok {
+		if err := b.checkTransCluster(ctx); err != nil {
+			return nil, err
+		}
+		db = tx.WithContext(ctx)
+	} else {
+		if db, err = b.clusterDB(ctx); err != nil {
+			return nil, err
+		}
+		if mode == ModeWrite || b.readFromMaster(ctx, db) {
+			db = db.WithContext(ctx).Clauses(dbresolver.Write)
+		} else {
+			db = db.WithContext(ctx).Clauses(dbresolver.Read)
+		}
+	}
+	if table != "" {
+		// 分表时指标等按逻辑表统计
+		db = db.Table(table).Set(settingTable, b.TableName)
+	}
+	return b.applyConventions(ctx, db), nil
+}
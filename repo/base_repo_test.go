@@ -0,0 +1,316 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// newDryRunDB 不连接数据库，只生成SQL
+func newDryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "root:root@tcp(localhost:3306)/openapi?charset=utf8mb4&parseTime=true&loc=Local",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatalf("Error creating database: %v", err)
+	}
+	return db
+}
+
+// fakeDB 不连接数据库，记录执行的SQL和事务，查询时返回tables中的表名
+type fakeDB struct {
+	mu     sync.Mutex
+	tables []string
+	execs  []string
+}
+
+func (s *fakeDB) Connect(ctx context.Context) (driver.Conn, error) { return s.Open("") }
+func (s *fakeDB) Driver() driver.Driver                            { return s }
+func (s *fakeDB) Open(name string) (driver.Conn, error)            { return &fakeDBConn{s: s}, nil }
+
+func (s *fakeDB) record(query string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.execs = append(s.execs, query)
+}
+
+func (s *fakeDB) executed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.execs...)
+}
+
+type fakeDBConn struct {
+	s *fakeDB
+}
+
+func (c *fakeDBConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeDBStmt{s: c.s, query: query}, nil
+}
+func (c *fakeDBConn) Close() error { return nil }
+func (c *fakeDBConn) Begin() (driver.Tx, error) {
+	c.s.record("BEGIN")
+	return fakeDBTx{s: c.s}, nil
+}
+
+type fakeDBTx struct {
+	s *fakeDB
+}
+
+func (tx fakeDBTx) Commit() error   { tx.s.record("COMMIT"); return nil }
+func (tx fakeDBTx) Rollback() error { tx.s.record("ROLLBACK"); return nil }
+
+type fakeDBStmt struct {
+	s     *fakeDB
+	query string
+}
+
+func (st *fakeDBStmt) Close() error  { return nil }
+func (st *fakeDBStmt) NumInput() int { return -1 }
+func (st *fakeDBStmt) Exec(args []driver.Value) (driver.Result, error) {
+	st.s.record(st.query)
+	return driver.RowsAffected(0), nil
+}
+func (st *fakeDBStmt) Query(args []driver.Value) (driver.Rows, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	return &fakeDBRows{tables: append([]string(nil), st.s.tables...)}, nil
+}
+
+type fakeDBRows struct {
+	tables []string
+}
+
+func (r *fakeDBRows) Columns() []string { return []string{"table_name"} }
+func (r *fakeDBRows) Close() error      { return nil }
+func (r *fakeDBRows) Next(dest []driver.Value) error {
+	if len(r.tables) == 0 {
+		return io.EOF
+	}
+	dest[0], r.tables = r.tables[0], r.tables[1:]
+	return nil
+}
+
+func newFakeDB(t *testing.T, s *fakeDB) *gorm.DB {
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sql.OpenDB(s), SkipInitializeWithVersion: true}), &gorm.Config{
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestQueryShardTable(t *testing.T) {
+	b := NewBaseRepo(newDryRunDB(t), WithTableName("hello_world"), WithShard(true, ShardTypeDay))
+
+	// 未设置后缀
+	if _, err := b.Query(context.Background(), ModeRead); !errors.Is(err, ErrNoShardSuffix) {
+		t.Fatalf("expect ErrNoShardSuffix, got %v", err)
+	}
+
+	ctx, err := b.SetSuffix(context.Background(), time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatalf("Error set suffix: %v", err)
+	}
+	db, err := b.Query(ctx, ModeRead)
+	if err != nil {
+		t.Fatalf("Error query: %v", err)
+	}
+	var rows []map[string]any
+	stmt := db.Find(&rows).Statement
+	if sql := stmt.SQL.String(); sql != "SELECT * FROM `hello_world_20260102`" {
+		t.Errorf("unexpected sql: %s", sql)
+	}
+}
+
+func TestShardSuffixes(t *testing.T) {
+	start := time.Date(2026, 1, 30, 10, 0, 0, 0, time.Local)
+	end := time.Date(2026, 2, 2, 10, 0, 0, 0, time.Local)
+	cases := []struct {
+		shardType ShardType
+		want      []string
+	}{
+		{ShardTypeDay, []string{"20260130", "20260131", "20260201", "20260202"}},
+		{ShardTypeWeek, []string{"20260126w", "20260202w"}},
+		{ShardTypeMonth, []string{"202601", "202602"}},
+	}
+	for _, c := range cases {
+		b := NewBaseRepo(nil, WithTableName("hello_world"), WithShard(true, c.shardType))
+		got, err := b.ShardSuffixes(start, end)
+		if err != nil {
+			t.Fatalf("Error list suffixes: %v", err)
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("shard type %d: got %v, want %v", c.shardType, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("shard type %d: got %v, want %v", c.shardType, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+// stubShardQuery 替换查询，每张分表返回一行，ID为日期；day为missing的分表不存在
+func stubShardQuery(t *testing.T, db *gorm.DB, missing int) *atomic.Int32 {
+	var running, maxRunning atomic.Int32
+	err := db.Callback().Query().Replace("gorm:query", func(db *gorm.DB) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			if m := maxRunning.Load(); n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		table := db.Statement.Table
+		day, _ := strconv.Atoi(table[len(table)-2:])
+		if day == missing {
+			_ = db.AddError(&gomysql.MySQLError{Number: 1146, Message: "Table doesn't exist"})
+			return
+		}
+		dest := db.Statement.Dest.(*[]rangeRow)
+		*dest = append(*dest, rangeRow{ID: int64(day)})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &maxRunning
+}
+
+type rangeRow struct {
+	ID int64
+}
+
+func TestRangeQuery(t *testing.T) {
+	db := newDryRunDB(t)
+	stubShardQuery(t, db, 3)
+	b := NewBaseRepo(db, WithTableName("hello_world"), WithShard(true, ShardTypeDay))
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local)
+
+	// 按分表顺序合并，跳过不存在的分表
+	rows, err := RangeQuery[rangeRow](context.Background(), b, start, end, nil, nil, WithParallel(3))
+	if err != nil {
+		t.Fatalf("Error range query: %v", err)
+	}
+	if want := []rangeRow{{1}, {2}, {4}, {5}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v, want %v", rows, want)
+	}
+
+	// 排序后截取
+	rows, err = RangeQuery(context.Background(), b, start, end,
+		func(db *gorm.DB) *gorm.DB { return db.Order("id desc") },
+		func(a, b rangeRow) bool { return a.ID > b.ID },
+		WithParallel(3), WithLimit(2),
+	)
+	if err != nil {
+		t.Fatalf("Error range query: %v", err)
+	}
+	if want := []rangeRow{{5}, {4}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v, want %v", rows, want)
+	}
+
+	if _, err := RangeQuery[rangeRow](context.Background(), b, start, end, nil, nil, WithMissingTable(true)); !isTableNotExist(err) {
+		t.Errorf("expect table not exist, got %v", err)
+	}
+}
+
+func TestRangeQueryInTrans(t *testing.T) {
+	db := newDryRunDB(t)
+	maxRunning := stubShardQuery(t, db, 0)
+	b := NewBaseRepo(db, WithTableName("hello_world"), WithShard(true, ShardTypeDay))
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local)
+
+	// 事务共用一个连接，不能并发
+	ctx := b.StartTrans(context.Background(), db)
+	rows, err := RangeQuery[rangeRow](ctx, b, start, end, nil, nil, WithParallel(4))
+	if err != nil {
+		t.Fatalf("Error range query: %v", err)
+	}
+	if len(rows) != 5 {
+		t.Errorf("expect 5 rows, got %v", rows)
+	}
+	if n := maxRunning.Load(); n != 1 {
+		t.Errorf("expect serial queries in trans, got %d", n)
+	}
+}
+
+func TestExpiredTables(t *testing.T) {
+	b := NewBaseRepo(nil, WithTableName("hello_world"), WithShard(true, ShardTypeDay), WithRetention(3))
+	tables := []string{
+		"hello_world_20260101",
+		"hello_world_20260108",
+		"hello_world_20260109",
+		"hello_world_20260110",
+		"hello_world_archive",
+	}
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.Local)
+	expired, err := b.expiredTables(tables, now)
+	if err != nil {
+		t.Fatalf("Error list expired: %v", err)
+	}
+	// 保留3个周期：10日、9日、8日
+	if len(expired) != 1 || expired[0] != "hello_world_20260101" {
+		t.Errorf("unexpected expired tables: %v", expired)
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	ctx := WithReadYourWrites(context.Background())
+	b := NewBaseRepo(newDryRunDB(t))
+	if b.readFromMaster(ctx, b.Db) {
+		t.Fatalf("should read from replica before writing")
+	}
+	markWritten(&gorm.DB{Config: &gorm.Config{}, Statement: &gorm.Statement{Context: ctx}, RowsAffected: 1})
+	if !b.readFromMaster(ctx, b.Db) {
+		t.Errorf("should read from master after writing")
+	}
+}
+
+func TestShardReposDedupe(t *testing.T) {
+	count := func(table string) (int, *BaseRepo) {
+		n, last := 0, (*BaseRepo)(nil)
+		for _, b := range ShardRepos() {
+			if b.TableName == table {
+				n++
+				last = b
+			}
+		}
+		return n, last
+	}
+	// 登记表是全局的，每次运行使用不同的表名
+	table := "test_registry_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	// 没有DB的不记录
+	NewBaseRepo(nil, WithTableName(table), WithShard(true, ShardTypeDay))
+	if n, _ := count(table); n != 0 {
+		t.Fatalf("repo without db should not be registered, got %d", n)
+	}
+	NewBaseRepo(newDryRunDB(t), WithTableName(table), WithShard(true, ShardTypeDay))
+	latest := NewBaseRepo(newDryRunDB(t), WithTableName(table), WithShard(true, ShardTypeDay))
+	if n, b := count(table); n != 1 || b != latest {
+		t.Errorf("expect the latest repo only, got %d", n)
+	}
+}
@@ -0,0 +1,221 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// KeyCluster 分库名
+type KeyCluster struct{}
+
+// KeyTransCluster 事务所在的分库名
+type KeyTransCluster struct{}
+
+var (
+	// ErrNoCluster 分库repo未设置分库，需先调用SetCluster
+	ErrNoCluster = errors.New("未设置分库")
+	// ErrCrossCluster 事务内访问了其它分库
+	ErrCrossCluster = errors.New("事务不能跨分库")
+)
+
+// Clusters 多个分库，每个分库独立的主从，按路由规则选择
+type Clusters struct {
+	names  []string
+	dbs    map[string]*gorm.DB
+	router ShardStrategy
+}
+
+// NewClusters 根据 MysqlConfig.Clusters 创建所有分库，未配置分库时返回nil，WithClusters(nil)只使用Db；
+// cleanup停止各分库的延迟检查并关闭连接池
+func NewClusters(c MysqlConfig) (*Clusters, func(), error) {
+	if len(c.Clusters) == 0 {
+		return nil, func() {}, nil
+	}
+	clusters := &Clusters{
+		dbs: make(map[string]*gorm.DB, len(c.Clusters)),
+	}
+	for _, cc := range c.Clusters {
+		if _, ok := clusters.dbs[cc.Name]; ok || cc.Name == "" {
+			clusters.close()
+			return nil, nil, fmt.Errorf("mysql cluster name error: %q", cc.Name)
+		}
+		db, err := openCluster(cc.Master, mergeReplicas(cc.Slave, cc.Replicas), c)
+		if err != nil {
+			clusters.close()
+			return nil, nil, fmt.Errorf("mysql cluster %s: %w", cc.Name, err)
+		}
+		clusters.names = append(clusters.names, cc.Name)
+		clusters.dbs[cc.Name] = db
+	}
+	router, err := newClusterRouter(c.Route, clusters.names)
+	if err != nil {
+		clusters.close()
+		return nil, nil, err
+	}
+	clusters.router = router
+	return clusters, clusters.close, nil
+}
+
+// close 停止所有分库的延迟检查并关闭连接池
+func (c *Clusters) close() {
+	for _, db := range c.dbs {
+		_ = Close(db)
+	}
+}
+
+// newClusterRouter 按路由规则创建选择分库的策略，策略返回分库名
+func newClusterRouter(route string, names []string) (ShardStrategy, error) {
+	switch route {
+	case "", "hash":
+		return indexRouter{names: names, strategy: HashStrategy{Count: len(names)}}, nil
+	case "mod":
+		return indexRouter{names: names, strategy: modStrategy{count: len(names)}}, nil
+	case "consistent":
+		return NewConsistentHash(100, names...), nil
+	default:
+		return nil, fmt.Errorf("mysql cluster route error: %s", route)
+	}
+}
+
+// indexRouter 将返回下标的策略转换为分库名
+type indexRouter struct {
+	names    []string
+	strategy ShardStrategy
+}
+
+func (r indexRouter) Suffix(key any) (string, error) {
+	suffix, err := r.strategy.Suffix(key)
+	if err != nil {
+		return "", err
+	}
+	i, err := strconv.Atoi(suffix)
+	if err != nil || i < 0 || i >= len(r.names) {
+		return "", fmt.Errorf("分库路由错误：%s", suffix)
+	}
+	return r.names[i], nil
+}
+
+// modStrategy 整数取模
+type modStrategy struct {
+	count int
+}
+
+func (m modStrategy) Suffix(key any) (string, error) {
+	// 以uint64取模，无符号整数不溢出，负数取绝对值
+	var id uint64
+	switch k := key.(type) {
+	case uint:
+		id = uint64(k)
+	case uint32:
+		id = uint64(k)
+	case uint64:
+		id = k
+	default:
+		v, ok := toInt64(key)
+		if !ok {
+			return "", fmt.Errorf("取模路由的依据必须为整数：%v", key)
+		}
+		id = uint64(v)
+		if v < 0 {
+			id = -id
+		}
+	}
+	return strconv.FormatUint(id%uint64(m.count), 10), nil
+}
+
+// Route 返回分库依据对应的分库名
+func (c *Clusters) Route(key any) (string, error) {
+	return c.router.Suffix(key)
+}
+
+// Get 按分库名返回DB
+func (c *Clusters) Get(name string) (*gorm.DB, bool) {
+	db, ok := c.dbs[name]
+	return db, ok
+}
+
+// Names 所有分库名
+func (c *Clusters) Names() []string {
+	return append([]string(nil), c.names...)
+}
+
+// WithClusters 开启分库，查询前需调用SetCluster；c为nil时只使用Db
+func WithClusters(c *Clusters) Option {
+	return func(repo *BaseRepo) {
+		repo.Clusters = c
+	}
+}
+
+func (b *BaseRepo) SetCluster(ctx context.Context, key any) (context.Context, error) {
+	if b.Clusters == nil {
+		return ctx, nil
+	}
+	name, err := b.Clusters.Route(key)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, KeyCluster{}, name), nil
+}
+
+// getCluster 从ctx中取出分库名
+func getCluster(ctx context.Context) string {
+	name, _ := ctx.Value(KeyCluster{}).(string)
+	return name
+}
+
+// getTransCluster 从ctx中取出事务所在的分库名
+func getTransCluster(ctx context.Context) string {
+	name, _ := ctx.Value(KeyTransCluster{}).(string)
+	return name
+}
+
+// clusterDB 返回ctx对应分库的DB，未开启分库时返回 Db
+func (b *BaseRepo) clusterDB(ctx context.Context) (*gorm.DB, error) {
+	if b.Clusters == nil {
+		return b.Db, nil
+	}
+	name := getCluster(ctx)
+	if name == "" {
+		return nil, ErrNoCluster
+	}
+	db, ok := b.Clusters.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoCluster, name)
+	}
+	return db, nil
+}
+
+// transCluster 当前repo开启事务时所在的分库名，未开启分库时为空
+func (b *BaseRepo) transCluster(ctx context.Context) string {
+	if b.Clusters == nil {
+		return ""
+	}
+	return getCluster(ctx)
+}
+
+// checkTransCluster ctx中的事务与当前分库不一致时返回 ErrCrossCluster，
+// 包括未开启分库的repo使用分库的事务，以及分库的repo使用默认库的事务
+func (b *BaseRepo) checkTransCluster(ctx context.Context) error {
+	trans := getTransCluster(ctx)
+	if b.Clusters == nil {
+		if trans != "" {
+			return fmt.Errorf("%w: %s -> 默认库", ErrCrossCluster, trans)
+		}
+		return nil
+	}
+	now := getCluster(ctx)
+	if now == "" {
+		return ErrNoCluster
+	}
+	if trans == "" {
+		return fmt.Errorf("%w: 默认库 -> %s", ErrCrossCluster, now)
+	}
+	if trans != now {
+		return fmt.Errorf("%w: %s -> %s", ErrCrossCluster, trans, now)
+	}
+	return nil
+}
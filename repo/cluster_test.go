@@ -0,0 +1,145 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestClusterRoute(t *testing.T) {
+	names := []string{"db0", "db1", "db2"}
+	for _, route := range []string{"hash", "mod", "consistent"} {
+		router, err := newClusterRouter(route, names)
+		if err != nil {
+			t.Fatalf("Error create router: %v", err)
+		}
+		name, err := router.Suffix(10)
+		if err != nil {
+			t.Fatalf("route %s: %v", route, err)
+		}
+		if name != "db0" && name != "db1" && name != "db2" {
+			t.Errorf("route %s: unexpected cluster %s", route, name)
+		}
+	}
+	// 超出int64的无符号整数、最小的负数不越界
+	router, _ := newClusterRouter("mod", names)
+	for key, want := range map[any]string{
+		uint64(math.MaxUint64): "db0",
+		uint64(1 << 63):        "db2",
+		int64(math.MinInt64):   "db2",
+		-4:                     "db1",
+	} {
+		if name, err := router.Suffix(key); err != nil || name != want {
+			t.Errorf("mod route %v: got %s %v, want %s", key, name, err, want)
+		}
+	}
+	if _, err := newClusterRouter("unknown", names); err == nil {
+		t.Errorf("unknown route should fail")
+	}
+}
+
+func TestNewClustersEmpty(t *testing.T) {
+	clusters, cleanup, err := NewClusters(MysqlConfig{})
+	if err != nil || clusters != nil {
+		t.Fatalf("expect nil clusters, got %v %v", clusters, err)
+	}
+	cleanup()
+}
+
+func TestCrossClusterTrans(t *testing.T) {
+	db0, db1 := newDryRunDB(t), newDryRunDB(t)
+	router, _ := newClusterRouter("mod", []string{"db0", "db1"})
+	clusters := &Clusters{
+		names:  []string{"db0", "db1"},
+		dbs:    map[string]*gorm.DB{"db0": db0, "db1": db1},
+		router: router,
+	}
+	b := NewBaseRepo(nil, WithTableName("hello_world"), WithClusters(clusters))
+
+	if _, err := b.Query(context.Background(), ModeRead); !errors.Is(err, ErrNoCluster) {
+		t.Fatalf("expect ErrNoCluster, got %v", err)
+	}
+	// 未设置分库时不退回默认库
+	var rows []map[string]any
+	if err := b.Write(context.Background()).Table("hello_world").Find(&rows).Error; !errors.Is(err, ErrNoCluster) {
+		t.Fatalf("Write: expect ErrNoCluster, got %v", err)
+	}
+	if err := b.Read(context.Background()).Table("hello_world").Find(&rows).Error; !errors.Is(err, ErrNoCluster) {
+		t.Fatalf("Read: expect ErrNoCluster, got %v", err)
+	}
+	if err := b.GetDB(context.Background()).Table("hello_world").Find(&rows).Error; !errors.Is(err, ErrNoCluster) {
+		t.Fatalf("GetDB: expect ErrNoCluster, got %v", err)
+	}
+	if err := b.CommitTrans(b.StartTrans(context.Background(), nil)); !errors.Is(err, ErrNoCluster) {
+		t.Fatalf("StartTrans: expect ErrNoCluster, got %v", err)
+	}
+
+	ctx, err := b.SetCluster(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Error set cluster: %v", err)
+	}
+	ctx = b.StartTrans(ctx, db0)
+	if _, err := b.Query(ctx, ModeWrite); err != nil {
+		t.Fatalf("Error query in trans: %v", err)
+	}
+
+	// 事务在db0，切换到db1
+	ctx, _ = b.SetCluster(ctx, 1)
+	if _, err := b.Query(ctx, ModeWrite); !errors.Is(err, ErrCrossCluster) {
+		t.Fatalf("expect ErrCrossCluster, got %v", err)
+	}
+	if err := b.Transaction(ctx, func(ctx context.Context) error { return nil }); !errors.Is(err, ErrCrossCluster) {
+		t.Fatalf("expect ErrCrossCluster, got %v", err)
+	}
+	if err := b.GetDB(ctx).Table("hello_world").Find(&rows).Error; !errors.Is(err, ErrCrossCluster) {
+		t.Fatalf("GetDB: expect ErrCrossCluster, got %v", err)
+	}
+	if err := b.CommitTrans(b.StartTrans(ctx, nil)); !errors.Is(err, ErrCrossCluster) {
+		t.Fatalf("StartTrans: expect ErrCrossCluster, got %v", err)
+	}
+}
+
+func TestCrossClusterTransWithoutClusters(t *testing.T) {
+	db0, plainDB := newDryRunDB(t), newDryRunDB(t)
+	router, _ := newClusterRouter("mod", []string{"db0"})
+	clusters := &Clusters{
+		names:  []string{"db0"},
+		dbs:    map[string]*gorm.DB{"db0": db0},
+		router: router,
+	}
+	b := NewBaseRepo(nil, WithTableName("hello_world"), WithClusters(clusters))
+	plain := NewBaseRepo(plainDB, WithTableName("hello_world"))
+	var rows []map[string]any
+
+	// 分库的事务中，未开启分库的repo不能使用该事务
+	ctx, _ := b.SetCluster(context.Background(), 0)
+	ctx = b.StartTrans(ctx, db0)
+	if _, err := plain.Query(ctx, ModeWrite); !errors.Is(err, ErrCrossCluster) {
+		t.Fatalf("expect ErrCrossCluster, got %v", err)
+	}
+	if err := plain.GetDB(ctx).Table("hello_world").Find(&rows).Error; !errors.Is(err, ErrCrossCluster) {
+		t.Fatalf("GetDB: expect ErrCrossCluster, got %v", err)
+	}
+	if err := plain.Transaction(ctx, func(ctx context.Context) error { return nil }); !errors.Is(err, ErrCrossCluster) {
+		t.Fatalf("Transaction: expect ErrCrossCluster, got %v", err)
+	}
+
+	// 默认库的事务中，分库的repo不能使用该事务，ctx中已设置分库时也一样
+	ctx, _ = b.SetCluster(context.Background(), 0)
+	ctx = plain.StartTrans(ctx, plainDB)
+	if _, err := plain.Query(ctx, ModeWrite); err != nil {
+		t.Fatalf("Error query in trans: %v", err)
+	}
+	if _, err := b.Query(ctx, ModeWrite); !errors.Is(err, ErrCrossCluster) {
+		t.Fatalf("expect ErrCrossCluster, got %v", err)
+	}
+	if err := b.GetDB(ctx).Table("hello_world").Find(&rows).Error; !errors.Is(err, ErrCrossCluster) {
+		t.Fatalf("GetDB: expect ErrCrossCluster, got %v", err)
+	}
+	if _, err := b.Query(plain.StartTrans(context.Background(), plainDB), ModeWrite); !errors.Is(err, ErrNoCluster) {
+		t.Fatalf("expect ErrNoCluster, got %v", err)
+	}
+}
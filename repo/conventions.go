@@ -0,0 +1,181 @@
+package repo
+
+import (
+	"api-gin/infra/errcode"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// 表的通用字段，见 infra/model.Timestamps、SoftDelete、Version
+const (
+	ColumnCreateTime = "create_time"
+	ColumnUpdateTime = "update_time"
+	ColumnDeletedAt  = "deleted_at"
+	ColumnVersion    = "version"
+)
+
+// settingTimestamps 由Query写入gorm的Settings，回调中判断是否需要填充时间
+const settingTimestamps = "repo:timestamps"
+
+// KeyWithDeleted 查询包含已软删除的数据
+type KeyWithDeleted struct{}
+
+// ErrVersionConflict 乐观锁冲突，可用 errors.Is 判断
+var ErrVersionConflict = errors.New("数据已被修改")
+
+func init() {
+	// 乐观锁冲突返回给客户端409
+	errcode.Register(ErrVersionConflict, errcode.ErrConflict)
+}
+
+// ConflictError 乐观锁冲突，version不匹配或数据不存在
+type ConflictError struct {
+	Table   string
+	Version int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s version %d", ErrVersionConflict.Error(), e.Table, e.Version)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// WithTimestamps 新增时填充create_time、update_time，更新时填充update_time
+func WithTimestamps() Option {
+	return func(repo *BaseRepo) {
+		repo.Timestamps = true
+	}
+}
+
+// WithSoftDelete 删除时填充deleted_at，查询时过滤已删除的数据
+func WithSoftDelete() Option {
+	return func(repo *BaseRepo) {
+		repo.SoftDelete = true
+	}
+}
+
+// WithVersion 更新时以version做乐观锁
+func WithVersion() Option {
+	return func(repo *BaseRepo) {
+		repo.Versioned = true
+	}
+}
+
+// WithDeleted 之后的查询包含已软删除的数据
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, KeyWithDeleted{}, true)
+}
+
+// applyConventions 按选项为Query返回的DB追加通用字段的处理
+func (b *BaseRepo) applyConventions(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if b.Timestamps {
+		db = db.Set(settingTimestamps, true)
+	}
+	if b.SoftDelete {
+		if withDeleted, _ := ctx.Value(KeyWithDeleted{}).(bool); !withDeleted {
+			db = db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: ColumnDeletedAt}, Value: nil})
+		}
+	}
+	return db
+}
+
+// registerCallbacks 注册repo使用的gorm回调
+func registerCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("repo:timestamps", fillTimestamps(true)); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("repo:timestamps", fillTimestamps(false)); err != nil {
+		return err
+	}
+	// 读写一致：标记请求内已写入
+	if err := cb.Create().After("gorm:create").Register("repo:mark_written", markWritten); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("repo:mark_written", markWritten); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("repo:mark_written", markWritten); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("repo:mark_written", markWritten)
+}
+
+// fillTimestamps 填充create_time、update_time，model中没有对应字段时忽略
+func fillTimestamps(create bool) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if on, _ := db.Get(settingTimestamps); on != true || db.Statement.Schema == nil {
+			return
+		}
+		now := time.Now()
+		if db.Statement.Schema.LookUpField(ColumnUpdateTime) != nil {
+			db.Statement.SetColumn(ColumnUpdateTime, now, true)
+		}
+		if field := db.Statement.Schema.LookUpField(ColumnCreateTime); create && field != nil {
+			setIfZero(db.Statement, field, now)
+		}
+	}
+}
+
+// setIfZero 字段为零值时才填充，Save、upsert时保留已有的值，支持批量新增
+func setIfZero(stmt *gorm.Statement, field *schema.Field, v any) {
+	set := func(rv reflect.Value) {
+		if _, zero := field.ValueOf(stmt.Context, rv); zero {
+			_ = field.Set(stmt.Context, rv, v)
+		}
+	}
+	rv := reflect.Indirect(stmt.ReflectValue)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		set(rv)
+	}
+}
+
+// updateVersion 以version为条件更新，成功后model的version加1
+func (b *BaseRepo) updateVersion(ctx context.Context, db *gorm.DB, m any) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(m); err != nil {
+		return err
+	}
+	field := stmt.Schema.LookUpField(ColumnVersion)
+	if field == nil {
+		return fmt.Errorf("%s 没有version字段", stmt.Schema.Name)
+	}
+	rv := reflect.ValueOf(m).Elem()
+	v, _ := field.ValueOf(ctx, rv)
+	version, ok := toInt64(v)
+	if !ok {
+		return fmt.Errorf("version字段必须为整数")
+	}
+	if err := field.Set(ctx, rv, version+1); err != nil {
+		return err
+	}
+
+	res := db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: ColumnVersion}, Value: version}).Updates(m)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = &ConflictError{Table: b.TableName, Version: version}
+	}
+	if res.Error != nil {
+		_ = field.Set(ctx, rv, version)
+		return res.Error
+	}
+	return nil
+}
+
+// softDelete 填充deleted_at代替删除
+func (b *BaseRepo) softDelete(db *gorm.DB, m any) error {
+	return db.Model(m).Updates(map[string]any{ColumnDeletedAt: time.Now()}).Error
+}
@@ -0,0 +1,101 @@
+package repo
+
+import (
+	"api-gin/infra/model"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testOrder struct {
+	ID   int64              `gorm:"column:id;primaryKey"`
+	Name model.Null[string] `gorm:"column:name"`
+	model.Timestamps
+	model.SoftDelete
+	model.Version
+}
+
+func (o *testOrder) TableName() string {
+	return "test_order"
+}
+
+func TestConventions(t *testing.T) {
+	db := newDryRunDB(t)
+	if err := registerCallbacks(db); err != nil {
+		t.Fatalf("Error register callbacks: %v", err)
+	}
+	rec := recordSQL(db)
+	r := NewRepo[testOrder](db, WithTimestamps(), WithSoftDelete(), WithVersion())
+	ctx := context.Background()
+
+	o := &testOrder{Name: model.NewNullValid("apple")}
+	if err := r.Create(ctx, o); err != nil {
+		t.Fatalf("Error create: %v", err)
+	}
+	if !o.CreateTime.Valid || !o.UpdateTime.Valid {
+		t.Errorf("timestamps not filled: %+v", o)
+	}
+
+	if _, err := r.List(ctx); err != nil {
+		t.Fatalf("Error list: %v", err)
+	}
+	if want := "SELECT * FROM `test_order` WHERE `test_order`.`deleted_at` IS NULL"; rec.sql != want {
+		t.Errorf("got sql %q, want %q", rec.sql, want)
+	}
+	if _, err := r.List(WithDeleted(ctx)); err != nil {
+		t.Fatalf("Error list: %v", err)
+	}
+	if strings.Contains(rec.sql, "deleted_at") {
+		t.Errorf("should include deleted rows: %q", rec.sql)
+	}
+
+	// dry run 影响行数为0，视为冲突
+	u := &testOrder{ID: 1, Name: model.NewNullValid("pear"), Version: model.Version{Version: 3}}
+	err := r.Update(ctx, u)
+	var conflict *ConflictError
+	if !errors.Is(err, ErrVersionConflict) || !errors.As(err, &conflict) || conflict.Version != 3 {
+		t.Fatalf("expect version conflict, got %v", err)
+	}
+	if !strings.Contains(rec.sql, "`version`=4") || !strings.Contains(rec.sql, "`test_order`.`version` = 3") {
+		t.Errorf("unexpected update sql %q", rec.sql)
+	}
+	if u.Version.Version != 3 {
+		t.Errorf("version should be restored after conflict, got %d", u.Version.Version)
+	}
+
+	if err := r.Delete(ctx, 1); err != nil {
+		t.Fatalf("Error delete: %v", err)
+	}
+	if !strings.HasPrefix(rec.sql, "UPDATE `test_order` SET `deleted_at`=") {
+		t.Errorf("soft delete should update deleted_at: %q", rec.sql)
+	}
+}
+
+func TestTimestampsKeepCreateTime(t *testing.T) {
+	db := newDryRunDB(t)
+	if err := registerCallbacks(db); err != nil {
+		t.Fatalf("Error register callbacks: %v", err)
+	}
+	r := NewRepo[testOrder](db, WithTimestamps())
+	ctx := context.Background()
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	o := &testOrder{ID: 1, Timestamps: model.Timestamps{CreateTime: model.NewNullValid(created)}}
+	if err := r.Create(ctx, o); err != nil {
+		t.Fatalf("Error create: %v", err)
+	}
+	// 已有create_time时保留，如upsert
+	if !o.CreateTime.V.Equal(created) || !o.UpdateTime.Valid {
+		t.Errorf("create_time should be kept: %+v", o.Timestamps)
+	}
+
+	list := []*testOrder{{ID: 2, Timestamps: model.Timestamps{CreateTime: model.NewNullValid(created)}}, {ID: 3}}
+	if err := r.BatchCreate(ctx, list, 10); err != nil {
+		t.Fatalf("Error batch create: %v", err)
+	}
+	if !list[0].CreateTime.V.Equal(created) || !list[1].CreateTime.Valid || list[1].CreateTime.V.Equal(created) {
+		t.Errorf("unexpected batch create_time: %+v, %+v", list[0].Timestamps, list[1].Timestamps)
+	}
+}
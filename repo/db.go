@@ -1,6 +1,7 @@
 package repo
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -11,17 +12,71 @@ import (
 )
 
 type MysqlConfig struct {
-	Master          []string `mapstructure:"master"`
-	Slave           []string `mapstructure:"slave"`
-	Log             string   `mapstructure:"log"` // info, warn, error
-	MaxIdleConns    int      `mapstructure:"max_idle_conns"`
-	MaxOpenConns    int      `mapstructure:"max_open_conns"`
-	ConnMaxLifetime int      `mapstructure:"conn_max_lifetime"`  // 单位 秒
-	ConnMaxIdleTime int      `mapstructure:"conn_max_idle_time"` // 单位 秒
+	Master          []string        `mapstructure:"master"`
+	Slave           []string        `mapstructure:"slave"`
+	Replicas        []ReplicaConfig `mapstructure:"replicas"` // 带权重的从库，与slave合并使用
+	Policy          string          `mapstructure:"policy"`   // 从库负载均衡：random, weighted, round_robin, least_inflight, ewma
+	Log             string          `mapstructure:"log"`      // info, warn, error
+	MaxIdleConns    int             `mapstructure:"max_idle_conns"`
+	MaxOpenConns    int             `mapstructure:"max_open_conns"`
+	ConnMaxLifetime int             `mapstructure:"conn_max_lifetime"`  // 单位 秒
+	ConnMaxIdleTime int             `mapstructure:"conn_max_idle_time"` // 单位 秒
+
+	Audit        AuditConfig        `mapstructure:"audit"`         // 变更审计
+	ReplicaCheck ReplicaCheckConfig `mapstructure:"replica_check"` // 从库延迟检查
+
+	Clusters []ClusterConfig `mapstructure:"clusters"` // 分库，每个库独立的主从
+	Route    string          `mapstructure:"route"`    // 分库路由：hash, consistent, mod
+}
+
+// ClusterConfig 分库配置
+type ClusterConfig struct {
+	Name   string   `mapstructure:"name"`
+	Master []string `mapstructure:"master"`
+	Slave  []string `mapstructure:"slave"`
+	// 带权重的从库，与slave合并使用
+	Replicas []ReplicaConfig `mapstructure:"replicas"`
+}
+
+// ReplicaConfig 从库配置
+type ReplicaConfig struct {
+	DSN    string `mapstructure:"dsn"`
+	Weight int    `mapstructure:"weight"` // 权重，weighted策略使用，默认1
+}
+
+// mergeReplicas 合并slave与replicas，slave的权重为1
+func mergeReplicas(slave []string, replicas []ReplicaConfig) []ReplicaConfig {
+	merged := make([]ReplicaConfig, 0, len(slave)+len(replicas))
+	for _, dsn := range slave {
+		merged = append(merged, ReplicaConfig{DSN: dsn, Weight: 1})
+	}
+	for _, r := range replicas {
+		if r.Weight <= 0 {
+			r.Weight = 1
+		}
+		merged = append(merged, r)
+	}
+	return merged
 }
 
 func NewDB(c MysqlConfig) (*gorm.DB, error) {
-	if len(c.Master) == 0 || len(c.Slave) == 0 {
+	return openCluster(c.Master, mergeReplicas(c.Slave, c.Replicas), c)
+}
+
+// OpenDB 供wire使用，同NewDB，cleanup停止延迟检查并关闭连接池
+func OpenDB(c MysqlConfig) (*gorm.DB, func(), error) {
+	db, err := NewDB(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, func() {
+		_ = Close(db)
+	}, nil
+}
+
+// openCluster 创建一组主从，连接池等参数取自c，失败时关闭已创建的连接池
+func openCluster(master []string, slave []ReplicaConfig, c MysqlConfig) (_ *gorm.DB, err error) {
+	if len(master) == 0 || len(slave) == 0 {
 		return nil, fmt.Errorf("no mysql master or slave config")
 	}
 	logLevel := logger.Silent
@@ -35,26 +90,64 @@ func NewDB(c MysqlConfig) (*gorm.DB, error) {
 	}
 
 	d, err := gorm.Open(mysql.New(mysql.Config{
-		DSN: c.Master[0],
+		DSN: master[0],
 	}), &gorm.Config{
 		Logger: logger.Default.LogMode(logLevel),
 	})
 	if err != nil {
 		return nil, err
 	}
+	var nodes *nodesPlugin
+	defer func() {
+		if err == nil {
+			return
+		}
+		if nodes != nil {
+			_ = nodes.close()
+		}
+		if pool, ok := d.Config.ConnPool.(*sql.DB); ok {
+			_ = pool.Close()
+		}
+	}()
+	if err := registerCallbacks(d); err != nil {
+		return nil, err
+	}
+	if err := d.Use(TracingPlugin{}); err != nil {
+		return nil, err
+	}
+	if c.Audit.Enable {
+		table := c.Audit.Table
+		if table == "" {
+			table = "audit_log"
+		}
+		if err := d.Use(NewAuditPlugin(TableSink{Table: table})); err != nil {
+			return nil, err
+		}
+	}
+	// 每个实例自行创建连接池，供延迟检查、健康检查使用
+	nodes, err = openNodes(master, slave)
+	if err != nil {
+		return nil, err
+	}
+	if nodes.selector, err = newSelector(c.Policy); err != nil {
+		return nil, err
+	}
+	if err := d.Use(nodes); err != nil {
+		return nil, err
+	}
 	// 主库
 	sources := make([]gorm.Dialector, 0)
-	for _, s := range c.Master {
+	for _, n := range nodes.masters {
 		sources = append(sources, mysql.New(mysql.Config{
-			DSN: s,
+			Conn: n.DB,
 		}))
 	}
 
 	// 从库
 	replicas := make([]gorm.Dialector, 0)
-	for _, s := range c.Slave {
+	for _, n := range nodes.replicas {
 		cfg := mysql.Config{
-			DSN: s,
+			Conn: n.DB,
 		}
 		replicas = append(replicas, mysql.New(cfg))
 	}
@@ -63,8 +156,8 @@ func NewDB(c MysqlConfig) (*gorm.DB, error) {
 		dbresolver.Register(dbresolver.Config{
 			Sources:           sources,
 			Replicas:          replicas,
-			Policy:            dbresolver.RandomPolicy{},
-			TraceResolverMode: true, // 是否在日志中输出 对应的主从信息
+			Policy:            nodes, // 按policy选择，摘除不可用的从库
+			TraceResolverMode: true,  // 是否在日志中输出 对应的主从信息
 		}).
 			SetMaxIdleConns(c.MaxIdleConns).
 			SetMaxOpenConns(c.MaxOpenConns).
@@ -74,6 +167,9 @@ func NewDB(c MysqlConfig) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.ReplicaCheck.Interval > 0 {
+		go nodes.watch(c.ReplicaCheck)
+	}
 
 	return d, nil
 }
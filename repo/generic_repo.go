@@ -0,0 +1,141 @@
+package repo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Filter 查询条件，对应 gorm 的 Scopes
+type Filter func(db *gorm.DB) *gorm.DB
+
+// Where 任意条件，参数同 gorm.DB.Where
+func Where(query any, args ...any) Filter {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(query, args...)
+	}
+}
+
+// Eq 字段等于
+func Eq(column string, value any) Filter {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(clause.Eq{Column: clause.Column{Name: column}, Value: value})
+	}
+}
+
+// Order 排序，参数同 gorm.DB.Order
+func Order(value any) Filter {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Order(value)
+	}
+}
+
+func scopes(filters []Filter) []func(*gorm.DB) *gorm.DB {
+	s := make([]func(*gorm.DB) *gorm.DB, 0, len(filters))
+	for _, f := range filters {
+		s = append(s, f)
+	}
+	return s
+}
+
+// Repo 基于BaseRepo的通用CRUD，自动使用ctx中的事务、分库和分表
+type Repo[T any] struct {
+	*BaseRepo
+}
+
+// NewRepo 创建通用repo，未指定表名时使用model的TableName，不参与wire
+func NewRepo[T any](db *gorm.DB, options ...Option) *Repo[T] {
+	var m T
+	if tabler, ok := any(&m).(interface{ TableName() string }); ok {
+		options = append([]Option{WithTableName(tabler.TableName())}, options...)
+	}
+	return &Repo[T]{
+		BaseRepo: NewBaseRepo(db, options...),
+	}
+}
+
+func (r *Repo[T]) Create(ctx context.Context, m *T) error {
+	db, err := r.Query(ctx, ModeWrite)
+	if err != nil {
+		return err
+	}
+	return db.Create(m).Error
+}
+
+// BatchCreate 批量新增，batchSize为每批条数
+func (r *Repo[T]) BatchCreate(ctx context.Context, list []*T, batchSize int) error {
+	if len(list) == 0 {
+		return nil
+	}
+	db, err := r.Query(ctx, ModeWrite)
+	if err != nil {
+		return err
+	}
+	return db.CreateInBatches(list, batchSize).Error
+}
+
+// GetByID 按主键查询，不存在时返回 gorm.ErrRecordNotFound
+func (r *Repo[T]) GetByID(ctx context.Context, id any) (*T, error) {
+	db, err := r.Query(ctx, ModeRead)
+	if err != nil {
+		return nil, err
+	}
+	var m T
+	if err := db.Where(clause.Eq{Column: clause.PrimaryColumn, Value: id}).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Update 按主键更新，零值字段不更新；model.Null 的V非零值且Valid为false时更新为NULL
+// 开启乐观锁时以version为条件，冲突返回 ConflictError
+func (r *Repo[T]) Update(ctx context.Context, m *T) error {
+	db, err := r.Query(ctx, ModeWrite)
+	if err != nil {
+		return err
+	}
+	if r.Versioned {
+		return r.updateVersion(ctx, db, m)
+	}
+	return db.Updates(m).Error
+}
+
+// Delete 按主键删除，开启软删除时填充deleted_at
+func (r *Repo[T]) Delete(ctx context.Context, id any) error {
+	db, err := r.Query(ctx, ModeWrite)
+	if err != nil {
+		return err
+	}
+	db = db.Where(clause.Eq{Column: clause.PrimaryColumn, Value: id})
+	if r.SoftDelete {
+		return r.softDelete(db, new(T))
+	}
+	return db.Delete(new(T)).Error
+}
+
+// List 按条件查询列表
+func (r *Repo[T]) List(ctx context.Context, filters ...Filter) ([]T, error) {
+	db, err := r.Query(ctx, ModeRead)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]T, 0)
+	if err := db.Scopes(scopes(filters)...).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Count 按条件计数
+func (r *Repo[T]) Count(ctx context.Context, filters ...Filter) (int64, error) {
+	db, err := r.Query(ctx, ModeRead)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	if err := db.Model(new(T)).Scopes(scopes(filters)...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
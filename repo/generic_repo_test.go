@@ -0,0 +1,175 @@
+package repo
+
+import (
+	"api-gin/infra/errcode"
+	"api-gin/infra/model"
+	"api-gin/infra/page"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder 记录最后执行的SQL，all为全部SQL
+type sqlRecorder struct {
+	logger.Interface
+	sql string
+	all []string
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	r.sql, _ = fc()
+	r.all = append(r.all, r.sql)
+}
+
+func recordSQL(db *gorm.DB) *sqlRecorder {
+	r := &sqlRecorder{Interface: logger.Discard}
+	db.Config.Logger = r
+	return r
+}
+
+type testUser struct {
+	ID   int64              `gorm:"column:id;primaryKey"`
+	Name model.Null[string] `gorm:"column:name"`
+	Age  model.Null[int]    `gorm:"column:age"`
+}
+
+func (u *testUser) TableName() string {
+	return "test_user"
+}
+
+func TestRepoCRUD(t *testing.T) {
+	db := newDryRunDB(t)
+	rec := recordSQL(db)
+	r := NewRepo[testUser](db)
+	ctx := context.Background()
+
+	cases := []struct {
+		run  func() error
+		want string
+	}{
+		{
+			run:  func() error { return r.Create(ctx, &testUser{Name: model.NewNullValid("tom")}) },
+			want: "INSERT INTO `test_user` (`name`,`age`) VALUES ('tom',NULL)",
+		},
+		{
+			run: func() error {
+				_, err := r.GetByID(ctx, 1)
+				return err
+			},
+			want: "SELECT * FROM `test_user` WHERE `test_user`.`id` = 1 ORDER BY `test_user`.`id` LIMIT 1",
+		},
+		{
+			// Age V非零值且Valid为false，更新为NULL
+			run:  func() error { return r.Update(ctx, &testUser{ID: 1, Age: model.NewNullInvalid(1)}) },
+			want: "UPDATE `test_user` SET `age`=NULL WHERE `id` = 1",
+		},
+		{
+			run:  func() error { return r.Delete(ctx, 1) },
+			want: "DELETE FROM `test_user` WHERE `test_user`.`id` = 1",
+		},
+		{
+			run: func() error {
+				_, err := r.List(ctx, Eq("name", "tom"), Order("id desc"))
+				return err
+			},
+			want: "SELECT * FROM `test_user` WHERE `name` = 'tom' ORDER BY id desc",
+		},
+		{
+			run: func() error {
+				_, err := r.Count(ctx, Where("age > ?", 18))
+				return err
+			},
+			want: "SELECT count(*) FROM `test_user` WHERE age > 18",
+		},
+	}
+	for _, c := range cases {
+		if err := c.run(); err != nil {
+			t.Fatalf("Error run %q: %v", c.want, err)
+		}
+		if rec.sql != c.want {
+			t.Errorf("got sql %q, want %q", rec.sql, c.want)
+		}
+	}
+}
+
+func TestRepoPageCursor(t *testing.T) {
+	db := newDryRunDB(t)
+	rec := recordSQL(db)
+	r := NewRepo[testUser](db)
+
+	cursor, _ := page.EncodeCursor(100)
+	resp, err := r.PageByCursor(context.Background(), page.Req{Size: 10, Cursor: cursor}, "id", true, Eq("name", "tom"))
+	if err != nil {
+		t.Fatalf("Error page by cursor: %v", err)
+	}
+	want := "SELECT * FROM `test_user` WHERE `id` < 100 AND `name` = 'tom' ORDER BY `id` DESC LIMIT 11"
+	if rec.sql != want {
+		t.Errorf("got sql %q, want %q", rec.sql, want)
+	}
+	if resp.HasMore || resp.NextCursor != "" {
+		t.Errorf("dry run should have no more page: %+v", resp)
+	}
+
+	// 无效游标为参数错误，返回400
+	_, err = r.PageByCursor(context.Background(), page.Req{Size: 10, Cursor: "!bad"}, "id", true)
+	if e := errcode.FromError(err); e == nil || e.Code != errcode.ErrInvalidParams.Code {
+		t.Errorf("expect invalid params, got %v", err)
+	}
+}
+
+func TestCursorOf(t *testing.T) {
+	db := newDryRunDB(t)
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&testUser{}); err != nil {
+		t.Fatalf("Error parse model: %v", err)
+	}
+	cursor, err := cursorOf(context.Background(), stmt, &testUser{ID: 7, Name: model.NewNullValid("tom")}, "name")
+	if err != nil {
+		t.Fatalf("Error cursor of: %v", err)
+	}
+	if last, _ := page.DecodeCursor(cursor); last != "tom" {
+		t.Errorf("unexpected cursor value %v", last)
+	}
+}
+
+func TestRepoPageOffset(t *testing.T) {
+	db := newDryRunDB(t)
+	rec := recordSQL(db)
+	// dry run 不执行，计数固定返回25
+	err := db.Callback().Query().After("gorm:query").Register("test:count", func(db *gorm.DB) {
+		if count, ok := db.Statement.Dest.(*int64); ok {
+			*count = 25
+			db.RowsAffected = 1
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRepo[testUser](db)
+
+	resp, err := r.Page(context.Background(), page.Req{Page: 2, Size: 10}, Eq("name", "tom"), Order("id desc"))
+	if err != nil {
+		t.Fatalf("Error page: %v", err)
+	}
+	// 计数与查询使用相同条件，计数不带排序和分页
+	want := []string{
+		"SELECT count(*) FROM `test_user` WHERE `name` = 'tom'",
+		"SELECT * FROM `test_user` WHERE `name` = 'tom' ORDER BY id desc LIMIT 10 OFFSET 10",
+	}
+	if !reflect.DeepEqual(rec.all, want) {
+		t.Errorf("got sql %q, want %q", rec.all, want)
+	}
+	if resp.Total != 25 || resp.Page != 2 || resp.Size != 10 || !resp.HasMore {
+		t.Errorf("unexpected resp: %+v", resp)
+	}
+
+	// 超出总数时不查询列表
+	rec.all = nil
+	if resp, err = r.Page(context.Background(), page.Req{Page: 4, Size: 10}); err != nil || len(rec.all) != 1 || resp.HasMore {
+		t.Errorf("unexpected page beyond total: %+v %q %v", resp, rec.all, err)
+	}
+}
@@ -2,24 +2,57 @@ package repo
 
 import (
 	"api-gin/infra/log"
+	"api-gin/infra/model"
+	"api-gin/infra/page"
+	"api-gin/infra/redis"
 	"context"
 	"gorm.io/gorm"
 )
 
+// HelloItem hello_world 的列表项
+type HelloItem struct {
+	ID   int64              `gorm:"column:id" json:"id"`
+	Name model.Null[string] `gorm:"column:name" json:"name"`
+	Age  model.Null[int]    `gorm:"column:age" json:"age"`
+}
+
+func (h *HelloItem) TableName() string {
+	return "hello_world"
+}
+
 type UserRepo struct {
 	baseRepo *BaseRepo
 	logger   *log.Logger
+	rdb      *redis.RedisClient // 未开启redis时为nil
 }
 
-func NewUserRepo(db *gorm.DB, logger *log.Logger) *UserRepo {
+func NewUserRepo(db *gorm.DB, logger *log.Logger, rdb *redis.RedisClient) *UserRepo {
 	return &UserRepo{
 		baseRepo: NewBaseRepo(db),
 		logger:   logger.NewLogger("UserRepo"),
+		rdb:      rdb,
 	}
 }
 
 func (u *UserRepo) Hello(ctx context.Context) string {
+	ctx, end := log.StartSpan(ctx, "UserRepo.Hello")
+	defer end()
 	// 假设查询了数据库
 	u.logger.Info(ctx, "hello")
+	if u.rdb != nil {
+		// 经WithContext发出的命令在当前trace下记录span
+		if err := u.rdb.WithContext(ctx).Incr("hello:count").Err(); err != nil {
+			u.logger.Error(ctx, err)
+		}
+	}
 	return "hello "
 }
+
+// List 按页码分页查询，name不为空时按name过滤
+func (u *UserRepo) List(ctx context.Context, req page.Req, name string) (*page.Resp[HelloItem], error) {
+	filters := []Filter{Order("id desc")}
+	if name != "" {
+		filters = append(filters, Eq("name", name))
+	}
+	return PageOffset[HelloItem](ctx, u.baseRepo, req, filters...)
+}
@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"api-gin/infra/metrics"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// instanceStart 当前语句的开始时间，存于gorm的InstanceSet
+	instanceStart = "repo:start"
+	// settingTable 逻辑表名，由Query写入gorm的Settings
+	settingTable = "repo:table"
+)
+
+// shardSuffix 分表后缀，如 _20260101、_20260105w、_202601、_3
+var shardSuffix = regexp.MustCompile(`_\d+w?$`)
+
+// MetricsPlugin 记录每条SQL的耗时，按操作、读写、表统计
+type MetricsPlugin struct {
+	Metrics *metrics.Metrics
+}
+
+func (p MetricsPlugin) Name() string {
+	return "repo:metrics"
+}
+
+func (p MetricsPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("*").Register("repo:metrics_before", startTimer); err != nil {
+		return err
+	}
+	if err := cb.Create().After("*").Register("repo:metrics_after", p.observe("create")); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("*").Register("repo:metrics_before", startTimer); err != nil {
+		return err
+	}
+	if err := cb.Query().After("*").Register("repo:metrics_after", p.observe("query")); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("*").Register("repo:metrics_before", startTimer); err != nil {
+		return err
+	}
+	if err := cb.Update().After("*").Register("repo:metrics_after", p.observe("update")); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("*").Register("repo:metrics_before", startTimer); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("*").Register("repo:metrics_after", p.observe("delete")); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("*").Register("repo:metrics_before", startTimer); err != nil {
+		return err
+	}
+	if err := cb.Row().After("*").Register("repo:metrics_after", p.observe("row")); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("*").Register("repo:metrics_before", startTimer); err != nil {
+		return err
+	}
+	return cb.Raw().After("*").Register("repo:metrics_after", p.observe("raw"))
+}
+
+func startTimer(db *gorm.DB) {
+	db.InstanceSet(instanceStart, time.Now())
+}
+
+func (p MetricsPlugin) observe(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(instanceStart)
+		if !ok {
+			return
+		}
+		p.Metrics.DBDuration.WithLabelValues(operation, resolverOf(db), logicalTable(db)).Observe(time.Since(v.(time.Time)).Seconds())
+	}
+}
+
+// logicalTable 语句的逻辑表名，分表只按基础表名统计，避免标签无限增长
+func logicalTable(db *gorm.DB) string {
+	if table, ok := db.Get(settingTable); ok {
+		return table.(string)
+	}
+	if db.Statement.Table == "" {
+		return "unknown"
+	}
+	return shardSuffix.ReplaceAllString(db.Statement.Table, "")
+}
+
+// resolverOf 语句实际使用的连接池是从库时为read，其余（主库、事务）为write
+func resolverOf(db *gorm.DB) string {
+	switch pool := db.Statement.ConnPool.(type) {
+	case *trackedPool:
+		return "read"
+	default:
+		if p := getNodes(db); p != nil {
+			if n, ok := p.byPool[pool]; ok && n.Role == RoleReplica {
+				return "read"
+			}
+		}
+	}
+	return "write"
+}
+
+// InstrumentDB 记录SQL耗时，并注册每个主从实例的连接池指标；m未开启时不处理
+func InstrumentDB(db *gorm.DB, m *metrics.Metrics) error {
+	if !m.Enabled() {
+		return nil
+	}
+	return instrument(db, m, "")
+}
+
+// InstrumentClusters 同InstrumentDB，作用于每个分库，实例名为 分库名/实例名；c为nil时不处理
+func InstrumentClusters(c *Clusters, m *metrics.Metrics) error {
+	if c == nil || !m.Enabled() {
+		return nil
+	}
+	for _, name := range c.names {
+		if err := instrument(c.dbs[name], m, name+"/"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func instrument(db *gorm.DB, m *metrics.Metrics, prefix string) error {
+	if err := db.Use(MetricsPlugin{Metrics: m}); err != nil {
+		return err
+	}
+	for _, n := range Nodes(db) {
+		if err := m.RegisterDBStats(prefix+n.Name, n.DB); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"api-gin/infra/metrics"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm"
+)
+
+func TestMetricsPlugin(t *testing.T) {
+	m, err := metrics.NewMetrics(metrics.Config{Enable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newDryRunDB(t)
+	if err := InstrumentDB(db, m); err != nil {
+		t.Fatalf("Error instrument db: %v", err)
+	}
+	ctx := context.Background()
+	var o testOrder
+	db.WithContext(ctx).Take(&o)
+	db.WithContext(ctx).Create(&testOrder{ID: 1})
+
+	if n := testutil.CollectAndCount(m.DBDuration); n != 2 {
+		t.Fatalf("expect 2 series, got %d", n)
+	}
+	families, err := m.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 未使用读写分离时均为主库
+	observed := make(map[string]bool)
+	for _, f := range families {
+		if f.GetName() != "db_query_duration_seconds" {
+			continue
+		}
+		for _, metric := range f.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["resolver"] != "write" || labels["table"] != "test_order" {
+				t.Errorf("unexpected labels: %v", labels)
+			}
+			observed[labels["operation"]] = true
+		}
+	}
+	if !observed["query"] || !observed["create"] {
+		t.Errorf("expect query and create, got %v", observed)
+	}
+
+	// 分表按逻辑表统计
+	shard := NewBaseRepo(db, WithTableName("hello_world"), WithShard(true, ShardTypeMonth))
+	sctx, _ := shard.SetSuffix(ctx, time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local))
+	q, err := shard.Query(sctx, ModeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Find(&[]testOrder{})
+	db.Table("hello_world_20260102").Find(&[]testOrder{})
+	tables := make(map[string]bool)
+	families, _ = m.Registry.Gather()
+	for _, f := range families {
+		if f.GetName() != "db_query_duration_seconds" {
+			continue
+		}
+		for _, metric := range f.GetMetric() {
+			for _, l := range metric.GetLabel() {
+				if l.GetName() == "table" {
+					tables[l.GetValue()] = true
+				}
+			}
+		}
+	}
+	if !reflect.DeepEqual(tables, map[string]bool{"test_order": true, "hello_world": true}) {
+		t.Errorf("unexpected tables: %v", tables)
+	}
+
+	// 未开启时不注册
+	disabled, _ := metrics.NewMetrics(metrics.Config{})
+	if err := InstrumentDB(newDryRunDB(t), disabled); err != nil {
+		t.Errorf("Error instrument disabled: %v", err)
+	}
+}
+
+func TestInstrumentClusters(t *testing.T) {
+	m, err := metrics.NewMetrics(metrics.Config{Enable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusters := &Clusters{names: []string{"db0", "db1"}, dbs: make(map[string]*gorm.DB)}
+	for _, name := range clusters.names {
+		db := newDryRunDB(t)
+		if err := db.Use(newTestNodes(t, 1)); err != nil {
+			t.Fatal(err)
+		}
+		clusters.dbs[name] = db
+	}
+	if err := InstrumentClusters(clusters, m); err != nil {
+		t.Fatalf("Error instrument clusters: %v", err)
+	}
+	if err := InstrumentClusters(nil, m); err != nil {
+		t.Errorf("nil clusters should be ignored: %v", err)
+	}
+
+	// 连接池指标按 分库名/实例名 区分
+	names := make(map[string]bool)
+	families, _ := m.Registry.Gather()
+	for _, f := range families {
+		if f.GetName() != "go_sql_open_connections" {
+			continue
+		}
+		for _, metric := range f.GetMetric() {
+			for _, l := range metric.GetLabel() {
+				if l.GetName() == "db_name" {
+					names[l.GetValue()] = true
+				}
+			}
+		}
+	}
+	want := map[string]bool{"db0/master-0": true, "db0/replica-0": true, "db1/master-0": true, "db1/replica-0": true}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
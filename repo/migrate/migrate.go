@@ -0,0 +1,335 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+/*
+数据库迁移：
+	文件名：<版本>_<名称>.up.sql、<版本>_<名称>.down.sql，版本为创建时间 20060102150405
+	记录表：schema_migrations，记录已执行的版本
+	并发：执行前获取 GET_LOCK，多个实例同时执行时只有一个生效，其余等待；查看状态不加锁
+	MySQL的DDL会隐式提交，迁移中途失败时需要人工处理后重试
+*/
+
+const (
+	table       = "schema_migrations"
+	lockName    = "schema_migrations_lock"
+	lockTimeout = 60 // 获取锁的等待时间，单位秒
+	versionFmt  = "20060102150405"
+
+	errTableNotExist = 1146 // ER_NO_SUCH_TABLE
+)
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status 迁移的执行状态
+type Status struct {
+	Migration
+	AppliedAt *time.Time // 为空表示未执行
+}
+
+var fileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load 读取目录中的迁移文件，按版本升序
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("迁移版本错误：%s", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("迁移版本重复：%d", version)
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("迁移缺少up：%d_%s", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Create 在dir中创建一对空的迁移文件，返回文件路径
+func Create(dir, name string, now time.Time) (up, down string, err error) {
+	if !regexp.MustCompile(`^\w+$`).MatchString(name) {
+		return "", "", fmt.Errorf("迁移名称只能包含字母、数字和下划线：%s", name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+	base := filepath.Join(dir, now.Format(versionFmt)+"_"+name)
+	up, down = base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- "+name+" up\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- "+name+" down\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New 创建迁移器，使用db的主库连接
+func New(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: sqlDB, migrations: migrations}, nil
+}
+
+// withLock 在同一个连接上获取锁并执行fn，GET_LOCK与连接绑定
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeout).Scan(&got); err != nil {
+		return err
+	}
+	if got.Int64 != 1 {
+		return fmt.Errorf("获取迁移锁超时")
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+	}()
+
+	_, err = conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS `"+table+"` ("+
+		"`version` bigint(20) NOT NULL, "+
+		"`name` varchar(255) NOT NULL, "+
+		"`applied_at` datetime NOT NULL, "+
+		"PRIMARY KEY (`version`)"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4")
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// queryer *sql.Conn、*sql.DB
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// applied 已执行的版本
+func applied(ctx context.Context, conn queryer) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT `version`, `applied_at` FROM `"+table+"`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at sql.NullTime
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		versions[version] = at.Time
+	}
+	return versions, rows.Err()
+}
+
+// Up 执行未执行的迁移，n为最多执行的个数，0为全部，返回已执行的迁移
+func (m *Migrator) Up(ctx context.Context, n int) ([]Migration, error) {
+	done := make([]Migration, 0)
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			if _, ok := versions[mg.Version]; ok {
+				continue
+			}
+			if n > 0 && len(done) >= n {
+				break
+			}
+			if err := execScript(ctx, conn, mg.Up); err != nil {
+				return fmt.Errorf("迁移%d_%s失败：%w", mg.Version, mg.Name, err)
+			}
+			_, err := conn.ExecContext(ctx, "INSERT INTO `"+table+"` (`version`, `name`, `applied_at`) VALUES (?, ?, ?)",
+				mg.Version, mg.Name, time.Now())
+			if err != nil {
+				return err
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down 按版本倒序回滚已执行的迁移，n为回滚的个数，返回已回滚的迁移
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	done := make([]Migration, 0)
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+			mg := m.migrations[i]
+			if _, ok := versions[mg.Version]; !ok {
+				continue
+			}
+			if strings.TrimSpace(mg.Down) == "" {
+				return fmt.Errorf("迁移%d_%s没有down，无法回滚", mg.Version, mg.Name)
+			}
+			if err := execScript(ctx, conn, mg.Down); err != nil {
+				return fmt.Errorf("回滚%d_%s失败：%w", mg.Version, mg.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM `"+table+"` WHERE `version` = ?", mg.Version); err != nil {
+				return err
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status 所有迁移及执行时间，只读不加锁，迁移执行中也不等待
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	versions, err := applied(ctx, m.db)
+	// 从未执行过迁移时记录表不存在
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == errTableNotExist {
+		versions, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		s := Status{Migration: mg}
+		if at, ok := versions[mg.Version]; ok {
+			s.AppliedAt = &at
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+func execScript(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range Split(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Split 按分号拆分SQL脚本，忽略引号和注释中的分号，去掉空语句
+func Split(script string) []string {
+	stmts := make([]string, 0)
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" && !isComment(s) {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+
+	var quote byte // 当前所在的引号
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			cur.WriteByte(c)
+			if c == '\\' && quote != '`' && i+1 < len(script) {
+				i++
+				cur.WriteByte(script[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			cur.WriteByte(c)
+		case c == '#' || (c == '-' && strings.HasPrefix(script[i:], "-- ")):
+			// 单行注释
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end - 1
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+		case c == ';':
+			flush()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+func isComment(s string) bool {
+	return strings.HasPrefix(s, "--") || strings.HasPrefix(s, "#")
+}
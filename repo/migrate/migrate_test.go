@@ -0,0 +1,156 @@
+package migrate
+
+import (
+	"api-gin/migrations"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20250102000000_add_age.up.sql":   {Data: []byte("ALTER TABLE t ADD age int;")},
+		"20250102000000_add_age.down.sql": {Data: []byte("ALTER TABLE t DROP age;")},
+		"20250101000000_create_t.up.sql":  {Data: []byte("CREATE TABLE t (id int);")},
+		"readme.md":                       {Data: []byte("ignored")},
+	}
+	ms, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Error load migrations: %v", err)
+	}
+	if len(ms) != 2 || ms[0].Name != "create_t" || ms[1].Version != 20250102000000 || ms[1].Down == "" {
+		t.Errorf("unexpected migrations: %+v", ms)
+	}
+
+	// 内置的迁移
+	if _, err := Load(migrations.FS); err != nil {
+		t.Errorf("Error load embedded migrations: %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	script := `-- comment; not a statement
+CREATE TABLE t (name varchar(10) DEFAULT 'a;b'); /* c; */
+INSERT INTO t VALUES ("x\";y");
+# trailing comment`
+	stmts := Split(script)
+	if len(stmts) != 2 {
+		t.Fatalf("unexpected statements: %q", stmts)
+	}
+	if stmts[0] != "CREATE TABLE t (name varchar(10) DEFAULT 'a;b')" || stmts[1] != `INSERT INTO t VALUES ("x\";y")` {
+		t.Errorf("unexpected statements: %q", stmts)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	up, down, err := Create(dir, "add_index", time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local))
+	if err != nil {
+		t.Fatalf("Error create migration: %v", err)
+	}
+	if _, err := os.Stat(up); err != nil {
+		t.Errorf("up file not created: %v", err)
+	}
+	if _, err := os.Stat(down); err != nil {
+		t.Errorf("down file not created: %v", err)
+	}
+	ms, err := Load(os.DirFS(dir))
+	if err != nil || len(ms) != 1 || ms[0].Version != 20260102030405 {
+		t.Errorf("unexpected created migration: %+v, %v", ms, err)
+	}
+	if _, _, err := Create(dir, "bad name", time.Now()); err == nil {
+		t.Errorf("invalid name should fail")
+	}
+}
+
+// fakeDriver 记录执行的SQL，查询记录表时返回applied中的版本，err不为空时返回err
+type fakeDriver struct {
+	queries []string
+	applied []int64
+	err     error
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+// Connect、Driver 实现driver.Connector，供sql.OpenDB使用，无需注册驱动
+func (d *fakeDriver) Connect(ctx context.Context) (driver.Conn, error) { return d.Open("") }
+func (d *fakeDriver) Driver() driver.Driver                            { return d }
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.queries = append(s.d.queries, s.query)
+	return driver.RowsAffected(0), nil
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.queries = append(s.d.queries, s.query)
+	if s.d.err != nil {
+		return nil, s.d.err
+	}
+	return &fakeRows{versions: s.d.applied}, nil
+}
+
+type fakeRows struct {
+	versions []int64
+}
+
+func (r *fakeRows) Columns() []string { return []string{"version", "applied_at"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.versions) == 0 {
+		return io.EOF
+	}
+	dest[0], dest[1] = r.versions[0], time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.versions = r.versions[1:]
+	return nil
+}
+
+func TestStatusWithoutLock(t *testing.T) {
+	d := &fakeDriver{applied: []int64{1}}
+	m := &Migrator{db: sql.OpenDB(d), migrations: []Migration{{Version: 1, Name: "a"}, {Version: 2, Name: "b"}}}
+	status, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Error status: %v", err)
+	}
+	if len(status) != 2 || status[0].AppliedAt == nil || status[1].AppliedAt != nil {
+		t.Errorf("unexpected status %+v", status)
+	}
+	for _, q := range d.queries {
+		if strings.Contains(q, "GET_LOCK") || strings.Contains(q, "CREATE TABLE") {
+			t.Errorf("status should only read, got %q", q)
+		}
+	}
+
+	// 记录表不存在时全部未执行
+	d.err = &mysql.MySQLError{Number: errTableNotExist, Message: "Table 'schema_migrations' doesn't exist"}
+	status, err = m.Status(context.Background())
+	if err != nil || len(status) != 2 || status[0].AppliedAt != nil {
+		t.Errorf("expect all pending, got %+v %v", status, err)
+	}
+}
@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"api-gin/infra/errcode"
+	"api-gin/infra/page"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PageOffset 按页码分页，返回当前页及总数
+func PageOffset[T any](ctx context.Context, b *BaseRepo, req page.Req, filters ...Filter) (*page.Resp[T], error) {
+	db, err := b.Query(ctx, ModeRead)
+	if err != nil {
+		return nil, err
+	}
+	// 条件先作用于DB，再创建可复用的会话执行计数和查询；Scopes在执行时才生效，计数无法去掉排序
+	for _, filter := range filters {
+		db = filter(db)
+	}
+	db = db.Session(&gorm.Session{})
+
+	var total int64
+	if err := db.Model(new(T)).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	resp := &page.Resp[T]{
+		List:  make([]T, 0),
+		Total: total,
+		Page:  req.GetPage(),
+		Size:  req.GetSize(),
+	}
+	if total == 0 || int64(req.Offset()) >= total {
+		return resp, nil
+	}
+	if err := db.Offset(req.Offset()).Limit(req.GetSize()).Find(&resp.List).Error; err != nil {
+		return nil, err
+	}
+	resp.HasMore = int64(req.Offset()+len(resp.List)) < total
+	return resp, nil
+}
+
+// PageCursor 按游标分页（keyset），column 为唯一且有序的排序字段，如主键id，desc 为倒序
+func PageCursor[T any](ctx context.Context, b *BaseRepo, req page.Req, column string, desc bool, filters ...Filter) (*page.Resp[T], error) {
+	db, err := b.Query(ctx, ModeRead)
+	if err != nil {
+		return nil, err
+	}
+	db = db.Scopes(scopes(filters)...)
+	if req.Cursor != "" {
+		last, err := page.DecodeCursor(req.Cursor)
+		if err != nil {
+			// 游标由客户端传入，无效时为参数错误
+			return nil, errcode.ErrInvalidParams.Wrap(err)
+		}
+		if n, ok := last.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				last = i
+			}
+		}
+		col := clause.Column{Name: column}
+		if desc {
+			db = db.Where(clause.Lt{Column: col, Value: last})
+		} else {
+			db = db.Where(clause.Gt{Column: col, Value: last})
+		}
+	}
+
+	size := req.GetSize()
+	list := make([]T, 0, size+1)
+	// 多查一条判断是否还有下一页
+	db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc}).Limit(size + 1).Find(&list)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	resp := &page.Resp[T]{
+		List: list,
+		Size: size,
+	}
+	if len(list) <= size {
+		return resp, nil
+	}
+	resp.List, resp.HasMore = list[:size], true
+	if resp.NextCursor, err = cursorOf(ctx, db.Statement, &list[size-1], column); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// cursorOf 读取记录中排序字段的值并编码为游标
+func cursorOf(ctx context.Context, stmt *gorm.Statement, last any, column string) (string, error) {
+	if stmt.Schema == nil {
+		return "", fmt.Errorf("游标分页需要使用model")
+	}
+	field := stmt.Schema.LookUpField(column)
+	if field == nil {
+		return "", fmt.Errorf("排序字段%s不存在", column)
+	}
+	v, _ := field.ValueOf(ctx, reflect.ValueOf(last).Elem())
+	// model.Null 等自定义类型取其数据库值
+	if valuer, ok := v.(driver.Valuer); ok {
+		var err error
+		if v, err = valuer.Value(); err != nil {
+			return "", err
+		}
+	}
+	return page.EncodeCursor(v)
+}
+
+// Page 按页码分页
+func (r *Repo[T]) Page(ctx context.Context, req page.Req, filters ...Filter) (*page.Resp[T], error) {
+	return PageOffset[T](ctx, r.BaseRepo, req, filters...)
+}
+
+// PageByCursor 按游标分页，column 为唯一且有序的排序字段
+func (r *Repo[T]) PageByCursor(ctx context.Context, req page.Req, column string, desc bool, filters ...Filter) (*page.Resp[T], error) {
+	return PageCursor[T](ctx, r.BaseRepo, req, column, desc, filters...)
+}
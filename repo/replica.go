@@ -0,0 +1,326 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ReplicaCheckConfig struct {
+	Interval int    `mapstructure:"interval"` // 检查间隔，单位秒，0为不检查
+	Timeout  int    `mapstructure:"timeout"`  // 单次检查超时，单位秒，默认1秒
+	MaxLag   int    `mapstructure:"max_lag"`  // 最大延迟，单位秒，超过后摘除
+	Query    string `mapstructure:"query"`    // 自定义延迟查询（如心跳表），返回延迟秒数；为空时使用 SHOW REPLICA STATUS
+}
+
+const (
+	RoleMaster  = "master"
+	RoleReplica = "replica"
+)
+
+// Node 一个数据库实例
+type Node struct {
+	Name   string // 如 master-0、replica-1
+	Role   string // master, replica
+	Weight int    // 权重，weighted策略使用
+	DB     *sql.DB
+
+	healthy  atomic.Bool
+	lag      atomic.Int64 // 最近一次的延迟，单位秒，-1为未知
+	chosen   atomic.Int64 // 被选中的次数
+	inFlight atomic.Int64 // 执行中的查询数
+	latency  ewma         // 查询耗时的指数加权平均
+}
+
+// Healthy 实例是否可用，从库延迟超限或不可达时为false
+func (n *Node) Healthy() bool {
+	return n.healthy.Load()
+}
+
+// Lag 从库最近一次检查的延迟，-1为未知
+func (n *Node) Lag() time.Duration {
+	lag := n.lag.Load()
+	if lag < 0 {
+		return -1
+	}
+	return time.Duration(lag) * time.Second
+}
+
+// nodesPlugin 以gorm插件的方式挂在DB上，保存一组主从的所有实例
+type nodesPlugin struct {
+	masters  []*Node
+	replicas []*Node
+	byPool   map[gorm.ConnPool]*Node
+	selector selector
+	stop     chan struct{} // 关闭后停止延迟检查
+	stopOnce sync.Once
+}
+
+const nodesPluginName = "repo:nodes"
+
+func (p *nodesPlugin) Name() string {
+	return nodesPluginName
+}
+
+func (p *nodesPlugin) Initialize(db *gorm.DB) error {
+	// 从库的执行中查询数、耗时统计到结果读取完为止
+	cb := db.Callback().Query()
+	if err := cb.Before("gorm:query").Register("repo:hold_release", holdQueryRelease); err != nil {
+		return err
+	}
+	return cb.After("gorm:query").Register("repo:release", releaseQuery)
+}
+
+// openNodes 为每个DSN创建连接池，交给dbresolver使用，失败时关闭已创建的连接池
+func openNodes(master []string, slave []ReplicaConfig) (_ *nodesPlugin, err error) {
+	p := &nodesPlugin{
+		byPool:   make(map[gorm.ConnPool]*Node),
+		selector: randomSelector{},
+		stop:     make(chan struct{}),
+	}
+	open := func(role string, i int, dsn string, weight int) (*Node, error) {
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			return nil, err
+		}
+		n := &Node{Name: role + "-" + strconv.Itoa(i), Role: role, Weight: weight, DB: db}
+		n.healthy.Store(true)
+		n.lag.Store(-1)
+		p.byPool[db] = n
+		return n, nil
+	}
+	defer func() {
+		if err != nil {
+			_ = p.close()
+		}
+	}()
+	for i, dsn := range master {
+		n, err := open(RoleMaster, i, dsn, 1)
+		if err != nil {
+			return nil, err
+		}
+		p.masters = append(p.masters, n)
+	}
+	for i, r := range slave {
+		n, err := open(RoleReplica, i, r.DSN, r.Weight)
+		if err != nil {
+			return nil, err
+		}
+		p.replicas = append(p.replicas, n)
+	}
+	return p, nil
+}
+
+// Nodes 返回NewDB创建的所有实例，非NewDB创建的DB返回空
+func Nodes(db *gorm.DB) []*Node {
+	p := getNodes(db)
+	if p == nil {
+		return nil
+	}
+	return append(append([]*Node(nil), p.masters...), p.replicas...)
+}
+
+func getNodes(db *gorm.DB) *nodesPlugin {
+	if db == nil {
+		return nil
+	}
+	if p, ok := db.Config.Plugins[nodesPluginName].(*nodesPlugin); ok {
+		return p
+	}
+	return nil
+}
+
+// healthyReplica 是否有可用的从库
+func (p *nodesPlugin) healthyReplica() bool {
+	for _, n := range p.replicas {
+		if n.Healthy() {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve 实现dbresolver.Policy，摘除不可用的从库后按selector选择，全部不可用时退回主库
+func (p *nodesPlugin) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	healthy := make([]*Node, 0, len(connPools))
+	for _, pool := range connPools {
+		n, ok := p.byPool[pool]
+		if !ok {
+			// 非openNodes创建的连接池，不做处理
+			return connPools[rand.Intn(len(connPools))]
+		}
+		if n.Healthy() {
+			healthy = append(healthy, n)
+		}
+	}
+	if len(healthy) == 0 {
+		if len(p.masters) > 0 && p.byPool[connPools[0]].Role == RoleReplica {
+			return p.masters[0].DB
+		}
+		return connPools[rand.Intn(len(connPools))]
+	}
+	n := p.selector.pick(healthy)
+	n.chosen.Add(1)
+	if n.Role == RoleReplica {
+		return &trackedPool{DB: n.DB, node: n}
+	}
+	return n.DB
+}
+
+// watch 定时检查从库延迟，Close后退出
+func (p *nodesPlugin) watch(c ReplicaCheckConfig) {
+	ticker := time.NewTicker(time.Duration(c.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		p.check(c)
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// close 停止延迟检查，关闭所有实例的连接池
+func (p *nodesPlugin) close() error {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	var errs []error
+	for _, n := range append(append([]*Node(nil), p.masters...), p.replicas...) {
+		errs = append(errs, n.DB.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Close 停止NewDB创建的DB的从库延迟检查，并关闭所有实例的连接池
+func Close(db *gorm.DB) error {
+	var errs []error
+	if p := getNodes(db); p != nil {
+		errs = append(errs, p.close())
+	}
+	// gorm.Open 创建的连接池
+	if pool, ok := db.Config.ConnPool.(*sql.DB); ok {
+		errs = append(errs, pool.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (p *nodesPlugin) check(c ReplicaCheckConfig) {
+	timeout := time.Duration(c.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	for _, n := range p.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		lag, err := replicaLag(ctx, n.DB, c.Query)
+		cancel()
+		if err != nil {
+			n.lag.Store(-1)
+			n.healthy.Store(false)
+			continue
+		}
+		n.lag.Store(lag)
+		n.healthy.Store(c.MaxLag <= 0 || lag <= int64(c.MaxLag))
+	}
+}
+
+// replicaLag 查询从库延迟，单位秒；复制中断时返回错误
+func replicaLag(ctx context.Context, db *sql.DB, query string) (int64, error) {
+	if query != "" {
+		var lag sql.NullInt64
+		if err := db.QueryRowContext(ctx, query).Scan(&lag); err != nil {
+			return 0, err
+		}
+		if !lag.Valid {
+			return 0, fmt.Errorf("从库延迟未知")
+		}
+		return lag.Int64, nil
+	}
+
+	// MySQL 8.0.22 之前只支持 SHOW SLAVE STATUS
+	status, err := showStatus(ctx, db, "SHOW REPLICA STATUS")
+	if err != nil {
+		if status, err = showStatus(ctx, db, "SHOW SLAVE STATUS"); err != nil {
+			return 0, err
+		}
+	}
+	for _, column := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		if v, ok := status[column]; ok {
+			if !v.Valid {
+				return 0, fmt.Errorf("从库复制已中断")
+			}
+			return strconv.ParseInt(v.String, 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("实例不是从库")
+}
+
+func showStatus(ctx context.Context, db *sql.DB, query string) (map[string]sql.NullString, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, fmt.Errorf("实例不是从库")
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	status := make(map[string]sql.NullString, len(columns))
+	for i, column := range columns {
+		status[column] = values[i]
+	}
+	return status, rows.Err()
+}
+
+// KeyReadYourWrites 请求内的写入标记
+type KeyReadYourWrites struct{}
+
+type writeMark struct {
+	written atomic.Bool
+}
+
+// WithReadYourWrites 开启读写一致，之后在该ctx中发生写入后，读操作都走主库
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, KeyReadYourWrites{}, &writeMark{})
+}
+
+// markWritten 回调中标记ctx已写入
+func markWritten(db *gorm.DB) {
+	if db.Error != nil || db.DryRun || db.RowsAffected == 0 || db.Statement.Context == nil {
+		return
+	}
+	if mark, ok := db.Statement.Context.Value(KeyReadYourWrites{}).(*writeMark); ok {
+		mark.written.Store(true)
+	}
+}
+
+// readFromMaster 读操作是否需要走主库：请求内已写入，或没有可用的从库
+func (b *BaseRepo) readFromMaster(ctx context.Context, db *gorm.DB) bool {
+	if mark, ok := ctx.Value(KeyReadYourWrites{}).(*writeMark); ok && mark.written.Load() {
+		return true
+	}
+	if p := getNodes(db); p != nil && len(p.replicas) > 0 && !p.healthyReplica() {
+		return true
+	}
+	return false
+}
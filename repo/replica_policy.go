@@ -0,0 +1,228 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// selector 从可用实例中选择一个，实例列表不为空
+type selector interface {
+	pick(nodes []*Node) *Node
+}
+
+func newSelector(policy string) (selector, error) {
+	switch policy {
+	case "", "random":
+		return randomSelector{}, nil
+	case "weighted":
+		return weightedSelector{}, nil
+	case "round_robin":
+		return &roundRobinSelector{}, nil
+	case "least_inflight":
+		return leastInFlightSelector{}, nil
+	case "ewma":
+		return ewmaSelector{}, nil
+	default:
+		return nil, fmt.Errorf("mysql policy error: %s", policy)
+	}
+}
+
+type randomSelector struct{}
+
+func (randomSelector) pick(nodes []*Node) *Node {
+	return nodes[rand.Intn(len(nodes))]
+}
+
+// weightedSelector 按权重随机
+type weightedSelector struct{}
+
+func (weightedSelector) pick(nodes []*Node) *Node {
+	total := 0
+	for _, n := range nodes {
+		total += max(n.Weight, 1)
+	}
+	r := rand.Intn(total)
+	for _, n := range nodes {
+		if r -= max(n.Weight, 1); r < 0 {
+			return n
+		}
+	}
+	return nodes[len(nodes)-1]
+}
+
+type roundRobinSelector struct {
+	i atomic.Uint64
+}
+
+func (s *roundRobinSelector) pick(nodes []*Node) *Node {
+	return nodes[(s.i.Add(1)-1)%uint64(len(nodes))]
+}
+
+// leastInFlightSelector 执行中查询最少的实例，相同时随机
+type leastInFlightSelector struct{}
+
+func (leastInFlightSelector) pick(nodes []*Node) *Node {
+	return pickMin(nodes, func(n *Node) float64 {
+		return float64(n.inFlight.Load())
+	})
+}
+
+// ewmaSelector 耗时的指数加权平均乘以(执行中查询数+1)最小的实例，未有耗时的优先
+type ewmaSelector struct{}
+
+func (ewmaSelector) pick(nodes []*Node) *Node {
+	return pickMin(nodes, func(n *Node) float64 {
+		return n.latency.value() * float64(n.inFlight.Load()+1)
+	})
+}
+
+func pickMin(nodes []*Node, score func(n *Node) float64) *Node {
+	best := make([]*Node, 0, len(nodes))
+	min := math.Inf(1)
+	for _, n := range nodes {
+		s := score(n)
+		switch {
+		case s < min:
+			min = s
+			best = append(best[:0], n)
+		case s == min:
+			best = append(best, n)
+		}
+	}
+	return best[rand.Intn(len(best))]
+}
+
+// ewma 指数加权平均，单位纳秒
+type ewma struct {
+	mu  sync.Mutex
+	avg float64
+}
+
+const ewmaAlpha = 0.3
+
+func (e *ewma) observe(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.avg == 0 {
+		e.avg = float64(d)
+		return
+	}
+	e.avg = e.avg*(1-ewmaAlpha) + float64(d)*ewmaAlpha
+}
+
+func (e *ewma) value() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.avg
+}
+
+// trackedPool 统计从库执行中的查询数和耗时。gorm的查询在结果读取完、rows关闭后结束，
+// Row、Rows等直接返回rows的查询在返回时即视为结束
+type trackedPool struct {
+	*sql.DB
+	node *Node
+}
+
+// keyQueryRelease 由查询回调写入ctx，QueryContext将结束统计的函数交给回调，读取完结果后调用
+type keyQueryRelease struct{}
+
+type queryRelease struct {
+	release func()
+}
+
+// holdQueryRelease 查询前在ctx中放入queryRelease
+func holdQueryRelease(db *gorm.DB) {
+	if db.Statement.Context == nil {
+		return
+	}
+	db.Statement.Context = context.WithValue(db.Statement.Context, keyQueryRelease{}, &queryRelease{})
+}
+
+// releaseQuery 查询回调执行完后，rows已读取并关闭，结束统计
+func releaseQuery(db *gorm.DB) {
+	if db.Statement.Context == nil {
+		return
+	}
+	if h, ok := db.Statement.Context.Value(keyQueryRelease{}).(*queryRelease); ok && h.release != nil {
+		h.release()
+		h.release = nil
+	}
+}
+
+func (p *trackedPool) track() func() {
+	p.node.inFlight.Add(1)
+	start := time.Now()
+	return func() {
+		p.node.inFlight.Add(-1)
+		p.node.latency.observe(time.Since(start))
+	}
+}
+
+func (p *trackedPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	release := p.track()
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if h, ok := ctx.Value(keyQueryRelease{}).(*queryRelease); ok && err == nil && h.release == nil {
+		h.release = release
+		return rows, nil
+	}
+	release()
+	return rows, err
+}
+
+func (p *trackedPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	defer p.track()()
+	return p.DB.QueryRowContext(ctx, query, args...)
+}
+
+func (p *trackedPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	defer p.track()()
+	return p.DB.ExecContext(ctx, query, args...)
+}
+
+// GetDBConn 实现gorm.GetDBConnector，db.DB() 可以取到原始连接池
+func (p *trackedPool) GetDBConn() (*sql.DB, error) {
+	return p.DB, nil
+}
+
+// NodeStat 实例的状态与统计，用于排查负载均衡
+type NodeStat struct {
+	Name     string        `json:"name"`
+	Role     string        `json:"role"`
+	Weight   int           `json:"weight"`
+	Healthy  bool          `json:"healthy"`
+	Lag      time.Duration `json:"lag"`       // -1为未知
+	Chosen   int64         `json:"chosen"`    // 被选中的次数
+	InFlight int64         `json:"in_flight"` // 执行中的查询数
+	Latency  time.Duration `json:"latency"`   // 查询耗时的指数加权平均
+}
+
+func (n *Node) Stat() NodeStat {
+	return NodeStat{
+		Name:     n.Name,
+		Role:     n.Role,
+		Weight:   n.Weight,
+		Healthy:  n.Healthy(),
+		Lag:      n.Lag(),
+		Chosen:   n.chosen.Load(),
+		InFlight: n.inFlight.Load(),
+		Latency:  time.Duration(n.latency.value()),
+	}
+}
+
+// NodeStats 返回NewDB创建的所有实例的状态与统计
+func NodeStats(db *gorm.DB) []NodeStat {
+	nodes := Nodes(db)
+	stats := make([]NodeStat, 0, len(nodes))
+	for _, n := range nodes {
+		stats = append(stats, n.Stat())
+	}
+	return stats
+}
@@ -0,0 +1,194 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func newTestNodes(t *testing.T, weights ...int) *nodesPlugin {
+	replicas := make([]ReplicaConfig, 0, len(weights))
+	for _, w := range weights {
+		replicas = append(replicas, ReplicaConfig{DSN: "root:root@tcp(localhost:3306)/openapi", Weight: w})
+	}
+	p, err := openNodes([]string{"root:root@tcp(localhost:3306)/openapi"}, replicas)
+	if err != nil {
+		t.Fatalf("Error open nodes: %v", err)
+	}
+	return p
+}
+
+func TestWeightedSelector(t *testing.T) {
+	p := newTestNodes(t, 1, 9)
+	p.selector, _ = newSelector("weighted")
+	pools := []gorm.ConnPool{p.replicas[0].DB, p.replicas[1].DB}
+	for i := 0; i < 10000; i++ {
+		p.Resolve(pools)
+	}
+	light, heavy := p.replicas[0].chosen.Load(), p.replicas[1].chosen.Load()
+	if heavy < light*5 {
+		t.Errorf("weighted selector should prefer the heavy replica: %d vs %d", light, heavy)
+	}
+}
+
+func TestLatencySelectors(t *testing.T) {
+	p := newTestNodes(t, 1, 1)
+	slow, fast := p.replicas[0], p.replicas[1]
+	slow.latency.observe(50 * time.Millisecond)
+	fast.latency.observe(5 * time.Millisecond)
+	if n := (ewmaSelector{}).pick(p.replicas); n != fast {
+		t.Errorf("ewma selector should pick the fast replica, got %s", n.Name)
+	}
+
+	fast.inFlight.Add(3)
+	if n := (leastInFlightSelector{}).pick(p.replicas); n != slow {
+		t.Errorf("least in-flight selector should pick the idle replica, got %s", n.Name)
+	}
+
+	rr := &roundRobinSelector{}
+	if rr.pick(p.replicas) != slow || rr.pick(p.replicas) != fast || rr.pick(p.replicas) != slow {
+		t.Errorf("round robin selector should cycle replicas")
+	}
+}
+
+func TestResolveUnhealthy(t *testing.T) {
+	p := newTestNodes(t, 1, 1)
+	for _, n := range p.replicas {
+		n.healthy.Store(false)
+	}
+	pools := []gorm.ConnPool{p.replicas[0].DB, p.replicas[1].DB}
+	if pool := p.Resolve(pools); pool != p.masters[0].DB {
+		t.Errorf("should fall back to master when no replica is healthy")
+	}
+	p.replicas[1].healthy.Store(true)
+	if pool, ok := p.Resolve(pools).(*trackedPool); !ok || pool.node != p.replicas[1] {
+		t.Errorf("should pick the healthy replica")
+	}
+}
+
+func TestWatchStop(t *testing.T) {
+	dsn := "root:root@tcp(127.0.0.1:1)/openapi?timeout=100ms"
+	p, err := openNodes([]string{dsn}, []ReplicaConfig{{DSN: dsn, Weight: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		p.watch(ReplicaCheckConfig{Interval: 1, Timeout: 1})
+		close(done)
+	}()
+	if err := p.close(); err != nil {
+		t.Fatalf("Error close: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("watch should stop after close")
+	}
+	// 重复关闭不panic
+	_ = p.close()
+}
+
+// fakeRowsDriver 返回固定的行，读取每行时回调next
+type fakeRowsDriver struct {
+	next func()
+}
+
+func (d *fakeRowsDriver) Open(name string) (driver.Conn, error) {
+	return &fakeRowsConn{d: d}, nil
+}
+
+// Connect、Driver 实现driver.Connector，供sql.OpenDB使用，无需注册驱动
+func (d *fakeRowsDriver) Connect(ctx context.Context) (driver.Conn, error) { return d.Open("") }
+func (d *fakeRowsDriver) Driver() driver.Driver                            { return d }
+
+type fakeRowsConn struct {
+	d *fakeRowsDriver
+}
+
+func (c *fakeRowsConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeRowsStmt{d: c.d}, nil
+}
+func (c *fakeRowsConn) Close() error              { return nil }
+func (c *fakeRowsConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type fakeRowsStmt struct {
+	d *fakeRowsDriver
+}
+
+func (s *fakeRowsStmt) Close() error  { return nil }
+func (s *fakeRowsStmt) NumInput() int { return -1 }
+func (s *fakeRowsStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+func (s *fakeRowsStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{d: s.d, left: 3}, nil
+}
+
+type fakeRows struct {
+	d    *fakeRowsDriver
+	left int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	r.d.next()
+	dest[0] = int64(r.left)
+	r.left--
+	return nil
+}
+
+func TestTrackedPoolReleaseAfterRead(t *testing.T) {
+	p := newTestNodes(t, 1)
+	node := p.replicas[0]
+	inFlight := make([]int64, 0)
+	d := &fakeRowsDriver{next: func() { inFlight = append(inFlight, node.inFlight.Load()) }}
+	pool := &trackedPool{DB: sql.OpenDB(d), node: node}
+
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: pool, SkipInitializeWithVersion: true}), &gorm.Config{
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(p); err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]any
+	if err := db.Table("hello_world").Find(&rows).Error; err != nil {
+		t.Fatalf("Error find: %v", err)
+	}
+	// 读取结果期间仍在执行中，读取完后释放
+	if len(rows) != 3 || !reflect.DeepEqual(inFlight, []int64{1, 1, 1}) {
+		t.Errorf("expect in flight while reading, got %v rows %v", inFlight, rows)
+	}
+	if n := node.inFlight.Load(); n != 0 {
+		t.Errorf("expect released after find, got %d", n)
+	}
+	if node.latency.value() == 0 {
+		t.Errorf("latency should be observed")
+	}
+
+	// 不经过gorm回调时，返回即释放
+	r, err := pool.QueryContext(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = r.Close()
+	if n := node.inFlight.Load(); n != 0 {
+		t.Errorf("expect released on return, got %d", n)
+	}
+}
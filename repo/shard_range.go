@@ -0,0 +1,213 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+type rangeConfig struct {
+	parallel    int
+	limit       int
+	skipMissing bool
+}
+
+type RangeOption func(*rangeConfig)
+
+// WithParallel 同时查询的分表数量，默认4
+func WithParallel(n int) RangeOption {
+	return func(c *rangeConfig) {
+		if n > 0 {
+			c.parallel = n
+		}
+	}
+}
+
+// WithLimit 合并后最多返回的条数，同时作用于每张分表
+func WithLimit(n int) RangeOption {
+	return func(c *rangeConfig) {
+		c.limit = n
+	}
+}
+
+// WithMissingTable 分表不存在时是否报错，默认跳过
+func WithMissingTable(fail bool) RangeOption {
+	return func(c *rangeConfig) {
+		c.skipMissing = !fail
+	}
+}
+
+// ShardPeriods 列出时间范围[start, end]涉及的每个分表周期的起始时间，仅支持按时间分表
+func (b *BaseRepo) ShardPeriods(start, end time.Time) ([]time.Time, error) {
+	if end.Before(start) {
+		return nil, fmt.Errorf("结束时间不能早于开始时间")
+	}
+	cur, err := b.periodStart(start)
+	if err != nil {
+		return nil, err
+	}
+	periods := make([]time.Time, 0)
+	for ; !cur.After(end); cur = b.addPeriods(cur, 1) {
+		periods = append(periods, cur)
+	}
+	return periods, nil
+}
+
+// periodStart 时间t所在分表周期的起始时间
+func (b *BaseRepo) periodStart(t time.Time) (time.Time, error) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch b.ShardType {
+	case ShardTypeDay:
+		return day, nil
+	case ShardTypeWeek:
+		// 周一为一周的开始，与defaultShardTool一致
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)), nil
+	case ShardTypeMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	default:
+		return time.Time{}, fmt.Errorf("非时间分表，不支持按周期处理")
+	}
+}
+
+// addPeriods 时间t往后n个分表周期，n为负数时往前
+func (b *BaseRepo) addPeriods(t time.Time, n int) time.Time {
+	switch b.ShardType {
+	case ShardTypeWeek:
+		return t.AddDate(0, 0, 7*n)
+	case ShardTypeMonth:
+		return time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
+
+// ShardSuffixes 列出时间范围[start, end]涉及的所有分表后缀
+func (b *BaseRepo) ShardSuffixes(start, end time.Time) ([]string, error) {
+	periods, err := b.ShardPeriods(start, end)
+	if err != nil {
+		return nil, err
+	}
+	suffixes := make([]string, 0, len(periods))
+	for _, t := range periods {
+		ctx, err := b.SetSuffix(context.Background(), t)
+		if err != nil {
+			return nil, err
+		}
+		suffix, _ := ctx.Value(KeyTableSuffix{}).(string)
+		if n := len(suffixes); n > 0 && suffixes[n-1] == suffix {
+			continue
+		}
+		suffixes = append(suffixes, suffix)
+	}
+	return suffixes, nil
+}
+
+// RangeQuery 在时间范围涉及的所有分表上并发执行查询并合并结果
+// query 在每张分表上追加条件，less 不为空时按其对合并结果排序，为空时按分表顺序返回
+// ctx中有事务时共用同一连接，依次查询
+func RangeQuery[T any](
+	ctx context.Context,
+	b *BaseRepo,
+	start, end time.Time,
+	query func(db *gorm.DB) *gorm.DB,
+	less func(a, b T) bool,
+	options ...RangeOption,
+) ([]T, error) {
+	c := rangeConfig{parallel: 4, skipMissing: true}
+	for _, option := range options {
+		option(&c)
+	}
+	if _, ok := getTransDb(ctx); ok {
+		c.parallel = 1
+	}
+	periods, err := b.ShardPeriods(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		sem      = make(chan struct{}, c.parallel)
+		parts    = make([][]T, len(periods))
+	)
+	for i, t := range periods {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+			part, err := queryShard[T](ctx, b, t, query, c)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			parts[i] = part
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	result := make([]T, 0)
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+	if less != nil {
+		sort.SliceStable(result, func(i, j int) bool {
+			return less(result[i], result[j])
+		})
+	}
+	if c.limit > 0 && len(result) > c.limit {
+		result = result[:c.limit]
+	}
+	return result, nil
+}
+
+func queryShard[T any](ctx context.Context, b *BaseRepo, t time.Time, query func(db *gorm.DB) *gorm.DB, c rangeConfig) ([]T, error) {
+	ctx, err := b.SetSuffix(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	db, err := b.Query(ctx, ModeRead)
+	if err != nil {
+		return nil, err
+	}
+	if query != nil {
+		db = query(db)
+	}
+	if c.limit > 0 {
+		db = db.Limit(c.limit)
+	}
+	part := make([]T, 0)
+	if err := db.Find(&part).Error; err != nil {
+		if c.skipMissing && isTableNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return part, nil
+}
+
+// isTableNotExist 表不存在，Error 1146
+func isTableNotExist(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1146
+}
@@ -0,0 +1,157 @@
+package repo
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ShardStrategy 分表策略，根据分表依据返回分表后缀
+type ShardStrategy interface {
+	Suffix(key any) (string, error)
+}
+
+// WithShardStrategy 使用自定义分表策略，SetSuffix 的第一个参数作为分表依据
+func WithShardStrategy(s ShardStrategy) Option {
+	return func(repo *BaseRepo) {
+		repo.ShardType = ShardTypeStrategy
+		repo.Strategy = s
+	}
+}
+
+// HashStrategy 对分表依据做hash后取模，适用于字符串等非数字依据
+type HashStrategy struct {
+	Count int // 分表数量
+}
+
+func (h HashStrategy) Suffix(key any) (string, error) {
+	if h.Count <= 0 {
+		return "", fmt.Errorf("分表数量必须大于0")
+	}
+	return strconv.FormatUint(uint64(hashKey(key)%uint32(h.Count)), 10), nil
+}
+
+// IDRange ID范围，左闭右开
+type IDRange struct {
+	Min    int64
+	Max    int64
+	Suffix string
+}
+
+// RangeStrategy 按ID范围分表，范围之外的ID返回错误
+type RangeStrategy struct {
+	Ranges []IDRange
+}
+
+func (r RangeStrategy) Suffix(key any) (string, error) {
+	id, ok := toInt64(key)
+	if !ok {
+		return "", fmt.Errorf("范围分表的依据必须为int64范围内的整数：%v", key)
+	}
+	for _, rg := range r.Ranges {
+		if id >= rg.Min && id < rg.Max {
+			return rg.Suffix, nil
+		}
+	}
+	return "", fmt.Errorf("ID %d 不在任何分表范围内", id)
+}
+
+// ConsistentHash 带虚拟节点的一致性hash，增减分表时只迁移少量数据
+type ConsistentHash struct {
+	replicas int               // 每个分表的虚拟节点数
+	ring     []uint32          // 有序的虚拟节点hash
+	nodes    map[uint32]string // 虚拟节点hash -> 分表后缀
+}
+
+// NewConsistentHash 创建一致性hash，replicas为每个分表的虚拟节点数，shards为分表后缀
+func NewConsistentHash(replicas int, shards ...string) *ConsistentHash {
+	if replicas <= 0 {
+		replicas = 100
+	}
+	c := &ConsistentHash{
+		replicas: replicas,
+		nodes:    make(map[uint32]string),
+	}
+	for _, shard := range shards {
+		for i := 0; i < replicas; i++ {
+			h := hashKey(shard + "#" + strconv.Itoa(i))
+			c.ring = append(c.ring, h)
+			c.nodes[h] = shard
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool { return c.ring[i] < c.ring[j] })
+	return c
+}
+
+func (c *ConsistentHash) Suffix(key any) (string, error) {
+	if len(c.ring) == 0 {
+		return "", fmt.Errorf("一致性hash没有分表")
+	}
+	h := hashKey(key)
+	i := sort.Search(len(c.ring), func(i int) bool { return c.ring[i] >= h })
+	if i == len(c.ring) {
+		i = 0
+	}
+	return c.nodes[c.ring[i]], nil
+}
+
+// KeyMove 分表调整后需要迁移的数据
+type KeyMove struct {
+	Key  any
+	From string
+	To   string
+}
+
+// MovedKeys 对比两种分表策略，返回分表后缀发生变化的key
+func MovedKeys(keys []any, from, to ShardStrategy) ([]KeyMove, error) {
+	moves := make([]KeyMove, 0)
+	for _, key := range keys {
+		f, err := from.Suffix(key)
+		if err != nil {
+			return nil, err
+		}
+		t, err := to.Suffix(key)
+		if err != nil {
+			return nil, err
+		}
+		if f != t {
+			moves = append(moves, KeyMove{Key: key, From: f, To: t})
+		}
+	}
+	return moves, nil
+}
+
+func hashKey(key any) uint32 {
+	h := fnv.New32a()
+	switch k := key.(type) {
+	case string:
+		_, _ = h.Write([]byte(k))
+	case []byte:
+		_, _ = h.Write(k)
+	default:
+		_, _ = fmt.Fprint(h, k)
+	}
+	return h.Sum32()
+}
+
+// toInt64 整数转为int64，超出int64范围的无符号整数返回false
+func toInt64(key any) (int64, bool) {
+	switch k := key.(type) {
+	case int:
+		return int64(k), true
+	case int32:
+		return int64(k), true
+	case int64:
+		return k, true
+	case uint:
+		return int64(k), uint64(k) <= math.MaxInt64
+	case uint32:
+		return int64(k), true
+	case uint64:
+		return int64(k), k <= math.MaxInt64
+	default:
+		return 0, false
+	}
+}
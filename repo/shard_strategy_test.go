@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestConsistentHashMoves(t *testing.T) {
+	keys := make([]any, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		keys = append(keys, fmt.Sprintf("user_%d", i))
+	}
+
+	hashMoves, err := MovedKeys(keys, HashStrategy{Count: 4}, HashStrategy{Count: 5})
+	if err != nil {
+		t.Fatalf("Error moved keys: %v", err)
+	}
+	ringMoves, err := MovedKeys(keys,
+		NewConsistentHash(100, "0", "1", "2", "3"),
+		NewConsistentHash(100, "0", "1", "2", "3", "4"),
+	)
+	if err != nil {
+		t.Fatalf("Error moved keys: %v", err)
+	}
+	t.Logf("hash moved %d, consistent hash moved %d", len(hashMoves), len(ringMoves))
+	// 新增一个分表，一致性hash理论上只迁移约1/5
+	if len(ringMoves) > len(keys)*3/10 {
+		t.Errorf("consistent hash moved too many keys: %d", len(ringMoves))
+	}
+	for _, m := range ringMoves {
+		if m.To != "4" {
+			t.Errorf("key %v should only move to the new shard, got %s", m.Key, m.To)
+			break
+		}
+	}
+}
+
+func TestRangeStrategy(t *testing.T) {
+	b := NewBaseRepo(nil, WithTableName("hello_world"), WithShardStrategy(RangeStrategy{
+		Ranges: []IDRange{
+			{Min: 0, Max: 1000, Suffix: "0"},
+			{Min: 1000, Max: 2000, Suffix: "1"},
+		},
+	}))
+	ctx, err := b.SetSuffix(context.Background(), int64(1500))
+	if err != nil {
+		t.Fatalf("Error set suffix: %v", err)
+	}
+	if table := b.GetTableName(ctx); table != "hello_world_1" {
+		t.Errorf("unexpected table: %s", table)
+	}
+	if _, err := b.SetSuffix(context.Background(), 3000); err == nil {
+		t.Errorf("id out of range should fail")
+	}
+
+	// 超出int64的无符号整数不能溢出为负数
+	neg := RangeStrategy{Ranges: []IDRange{{Min: -10, Max: 0, Suffix: "neg"}}}
+	if suffix, err := neg.Suffix(uint64(math.MaxUint64)); err == nil {
+		t.Errorf("uint64 overflow should fail, got %s", suffix)
+	}
+	if suffix, err := neg.Suffix(uint64(math.MaxInt64)); err == nil {
+		t.Errorf("max int64 out of range should fail, got %s", suffix)
+	}
+}
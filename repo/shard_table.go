@@ -0,0 +1,243 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WithRetention 分表保留最近n个周期（含当前周期），更早的分表由 PruneShards 清理
+func WithRetention(n int) Option {
+	return func(repo *BaseRepo) {
+		repo.Retention = n
+	}
+}
+
+// WithAutoCreate 写入前按模板表 TableName 自动创建缺失的分表
+func WithAutoCreate() Option {
+	return func(repo *BaseRepo) {
+		repo.AutoCreate = true
+	}
+}
+
+var shardRepos = struct {
+	sync.Mutex
+	tables []string
+	repos  map[string]*BaseRepo
+}{repos: make(map[string]*BaseRepo)}
+
+// registerShardRepo 按表名记录分表的repo，供预建表等任务使用；同一张表只保留最后创建的repo，没有DB的不记录
+func registerShardRepo(b *BaseRepo) {
+	if b.Db == nil && b.Clusters == nil {
+		return
+	}
+	shardRepos.Lock()
+	defer shardRepos.Unlock()
+	if _, ok := shardRepos.repos[b.TableName]; !ok {
+		shardRepos.tables = append(shardRepos.tables, b.TableName)
+	}
+	shardRepos.repos[b.TableName] = b
+}
+
+// ShardRepos 返回所有已创建的分表repo，每张表一个，按首次创建的顺序
+func ShardRepos() []*BaseRepo {
+	shardRepos.Lock()
+	defer shardRepos.Unlock()
+	list := make([]*BaseRepo, 0, len(shardRepos.tables))
+	for _, table := range shardRepos.tables {
+		list = append(list, shardRepos.repos[table])
+	}
+	return list
+}
+
+// EnsureTable 确保ctx对应的分表存在，不存在时以 TableName 为模板创建
+func (b *BaseRepo) EnsureTable(ctx context.Context) error {
+	if b.ShardType == ShardNone {
+		return nil
+	}
+	table, err := b.ResolveTableName(ctx)
+	if err != nil {
+		return err
+	}
+	key := createdKey(ctx, table)
+	if _, ok := b.created.Load(key); ok {
+		return nil
+	}
+	// DDL会隐式提交事务，不能使用ctx中的事务
+	err = b.Write(ctx).
+		Exec("CREATE TABLE IF NOT EXISTS ? LIKE ?", clause.Table{Name: table}, clause.Table{Name: b.TableName}).
+		Error
+	if err != nil {
+		return fmt.Errorf("创建分表%s失败：%w", table, err)
+	}
+	b.created.Store(key, struct{}{})
+	return nil
+}
+
+// createdKey 已创建分表的缓存key，各分库的同名分表分别记录
+func createdKey(ctx context.Context, table string) string {
+	return getCluster(ctx) + "." + table
+}
+
+// eachCluster 开启分库且ctx中未设置分库时，依次在每个分库上执行fn，否则只在ctx对应的库上执行
+func (b *BaseRepo) eachCluster(ctx context.Context, fn func(ctx context.Context) error) error {
+	if b.Clusters == nil || getCluster(ctx) != "" {
+		return fn(ctx)
+	}
+	for _, name := range b.Clusters.Names() {
+		if err := fn(context.WithValue(ctx, KeyCluster{}, name)); err != nil {
+			return fmt.Errorf("分库%s：%w", name, err)
+		}
+	}
+	return nil
+}
+
+// PreCreate 创建当前及之后n个周期的分表，仅支持按时间分表
+func (b *BaseRepo) PreCreate(ctx context.Context, n int) error {
+	now := time.Now()
+	periods, err := b.ShardPeriods(now, b.addPeriods(now, n))
+	if err != nil {
+		return err
+	}
+	for _, t := range periods {
+		sctx, err := b.SetSuffix(ctx, t)
+		if err != nil {
+			return err
+		}
+		if err := b.EnsureTable(sctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PreCreateShardTables 为所有开启自动建表的时间分表repo预建之后n个周期的分表
+func PreCreateShardTables(ctx context.Context, n int) error {
+	for _, b := range ShardRepos() {
+		if !b.AutoCreate {
+			continue
+		}
+		switch b.ShardType {
+		case ShardTypeDay, ShardTypeWeek, ShardTypeMonth:
+			if err := b.PreCreate(ctx, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ShardTables 通过information_schema列出当前库中该repo的所有分表，开启分库时需在ctx中设置分库
+func (b *BaseRepo) ShardTables(ctx context.Context) ([]string, error) {
+	tables := make([]string, 0)
+	pattern := strings.NewReplacer("\\", "\\\\", "_", "\\_", "%", "\\%").Replace(b.TableName) + "\\_%"
+	err := b.Write(ctx).
+		Raw("SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name LIKE ? ORDER BY table_name", pattern).
+		Scan(&tables).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// ExpiredShards 返回超出保留周期的分表，未设置保留周期时返回空
+func (b *BaseRepo) ExpiredShards(ctx context.Context, now time.Time) ([]string, error) {
+	if b.Retention <= 0 {
+		return nil, nil
+	}
+	tables, err := b.ShardTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return b.expiredTables(tables, now)
+}
+
+// expiredTables 按后缀解析分表周期，早于保留起点的即为过期，无法解析的表忽略
+func (b *BaseRepo) expiredTables(tables []string, now time.Time) ([]string, error) {
+	var layout string
+	switch b.ShardType {
+	case ShardTypeDay:
+		layout = "20060102"
+	case ShardTypeWeek:
+		layout = "20060102w"
+	case ShardTypeMonth:
+		layout = "200601"
+	default:
+		return nil, fmt.Errorf("非时间分表，不支持保留策略")
+	}
+	start, err := b.periodStart(now)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := b.addPeriods(start, 1-b.Retention)
+
+	expired := make([]string, 0)
+	prefix := b.TableName + "_"
+	for _, table := range tables {
+		suffix, ok := strings.CutPrefix(table, prefix)
+		if !ok {
+			continue
+		}
+		t, err := time.ParseInLocation(layout, suffix, now.Location())
+		if err != nil {
+			continue
+		}
+		if t.Before(cutoff) {
+			expired = append(expired, table)
+		}
+	}
+	return expired, nil
+}
+
+type PruneOptions struct {
+	DryRun        bool   // 只返回将要执行的SQL，不执行
+	ArchiveSchema string // 不为空时将过期分表移动到该库，否则删除
+}
+
+// PruneShards 清理过期分表，返回执行（或将要执行）的SQL；开启分库且ctx中未设置分库时清理所有分库
+func (b *BaseRepo) PruneShards(ctx context.Context, opt PruneOptions) ([]string, error) {
+	done := make([]string, 0)
+	err := b.eachCluster(ctx, func(ctx context.Context) error {
+		return b.pruneShards(ctx, opt, &done)
+	})
+	return done, err
+}
+
+// pruneShards 清理ctx对应的库中的过期分表，执行的SQL追加到done
+func (b *BaseRepo) pruneShards(ctx context.Context, opt PruneOptions, done *[]string) error {
+	expired, err := b.ExpiredShards(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, table := range expired {
+		var sql string
+		var vars []any
+		if opt.ArchiveSchema != "" {
+			sql = "RENAME TABLE ? TO ?"
+			vars = []any{clause.Table{Name: table}, clause.Table{Name: opt.ArchiveSchema + "." + table}}
+		} else {
+			sql = "DROP TABLE IF EXISTS ?"
+			vars = []any{clause.Table{Name: table}}
+		}
+		db := b.Write(ctx)
+		if db.Error != nil {
+			return db.Error
+		}
+		stmt := db.Session(&gorm.Session{DryRun: true}).Exec(sql, vars...).Statement
+		explained := db.Dialector.Explain(stmt.SQL.String(), stmt.Vars...)
+		if !opt.DryRun {
+			if err := db.Exec(sql, vars...).Error; err != nil {
+				return fmt.Errorf("清理分表%s失败：%w", table, err)
+			}
+			b.created.Delete(createdKey(ctx, table))
+		}
+		*done = append(*done, explained)
+	}
+	return nil
+}
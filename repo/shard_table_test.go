@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestPruneShards(t *testing.T) {
+	today := "test_prune_" + time.Now().Format("20060102")
+	s := &fakeDB{tables: []string{"test_prune_20200101", today}}
+	b := NewBaseRepo(newFakeDB(t, s), WithTableName("test_prune"), WithShard(true, ShardTypeDay), WithRetention(3))
+	want := []string{"DROP TABLE IF EXISTS `test_prune_20200101`"}
+
+	sqls, err := b.PruneShards(context.Background(), PruneOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Error dry run: %v", err)
+	}
+	if !reflect.DeepEqual(sqls, want) || len(s.executed()) != 0 {
+		t.Errorf("dry run should not execute, got %v, executed %v", sqls, s.executed())
+	}
+
+	sqls, err = b.PruneShards(context.Background(), PruneOptions{})
+	if err != nil {
+		t.Fatalf("Error prune: %v", err)
+	}
+	if !reflect.DeepEqual(sqls, want) || !reflect.DeepEqual(s.executed(), want) {
+		t.Errorf("unexpected prune %v, executed %v", sqls, s.executed())
+	}
+
+	sqls, err = b.PruneShards(context.Background(), PruneOptions{DryRun: true, ArchiveSchema: "archive"})
+	if err != nil || !reflect.DeepEqual(sqls, []string{"RENAME TABLE `test_prune_20200101` TO `archive`.`test_prune_20200101`"}) {
+		t.Errorf("unexpected archive %v, %v", sqls, err)
+	}
+}
+
+func TestPruneShardsClusters(t *testing.T) {
+	s0 := &fakeDB{tables: []string{"test_prune_20200101"}}
+	s1 := &fakeDB{tables: []string{"test_prune_20200102"}}
+	router, _ := newClusterRouter("mod", []string{"db0", "db1"})
+	clusters := &Clusters{
+		names:  []string{"db0", "db1"},
+		dbs:    map[string]*gorm.DB{"db0": newFakeDB(t, s0), "db1": newFakeDB(t, s1)},
+		router: router,
+	}
+	b := NewBaseRepo(nil, WithTableName("test_prune"), WithShard(true, ShardTypeDay), WithRetention(3), WithClusters(clusters))
+
+	// 没有Db时dry run不panic，未设置分库时清理所有分库
+	sqls, err := b.PruneShards(context.Background(), PruneOptions{DryRun: true})
+	if err != nil || len(sqls) != 2 {
+		t.Fatalf("unexpected dry run %v, %v", sqls, err)
+	}
+	if _, err := b.PruneShards(context.Background(), PruneOptions{}); err != nil {
+		t.Fatalf("Error prune: %v", err)
+	}
+	if e0, e1 := s0.executed(), s1.executed(); len(e0) != 1 || len(e1) != 1 || e0[0] == e1[0] {
+		t.Errorf("each cluster should drop its own table, got %v, %v", e0, e1)
+	}
+
+	// 设置分库时只清理该分库
+	ctx, _ := b.SetCluster(context.Background(), 1)
+	if sqls, err := b.PruneShards(ctx, PruneOptions{DryRun: true}); err != nil || len(sqls) != 1 || sqls[0] != "DROP TABLE IF EXISTS `test_prune_20200102`" {
+		t.Errorf("unexpected cluster prune %v, %v", sqls, err)
+	}
+}
+
+func TestEnsureTable(t *testing.T) {
+	s := &fakeDB{}
+	b := NewBaseRepo(newFakeDB(t, s), WithTableName("test_ensure"), WithShard(true, ShardTypeDay), WithAutoCreate())
+	ctx, _ := b.SetSuffix(context.Background(), time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local))
+	for i := 0; i < 2; i++ {
+		if err := b.EnsureTable(ctx); err != nil {
+			t.Fatalf("Error ensure table: %v", err)
+		}
+	}
+	// 重复调用只建一次
+	want := []string{"CREATE TABLE IF NOT EXISTS `test_ensure_20260102` LIKE `test_ensure`"}
+	if got := s.executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// 写入前自动建表
+	next, _ := b.SetSuffix(context.Background(), time.Date(2026, 1, 3, 0, 0, 0, 0, time.Local))
+	if _, err := b.Query(next, ModeWrite); err != nil {
+		t.Fatalf("Error query: %v", err)
+	}
+	if got := s.executed(); len(got) != 2 || got[1] != "CREATE TABLE IF NOT EXISTS `test_ensure_20260103` LIKE `test_ensure`" {
+		t.Errorf("write should create the table, got %q", got)
+	}
+}
+
+func TestPreCreate(t *testing.T) {
+	s := &fakeDB{}
+	b := NewBaseRepo(newFakeDB(t, s), WithTableName("test_precreate"), WithShard(true, ShardTypeMonth), WithAutoCreate())
+	now := time.Now()
+	want := []string{
+		"CREATE TABLE IF NOT EXISTS `test_precreate_" + now.Format("200601") + "` LIKE `test_precreate`",
+		"CREATE TABLE IF NOT EXISTS `test_precreate_" + b.addPeriods(now, 1).Format("200601") + "` LIKE `test_precreate`",
+	}
+	for i := 0; i < 2; i++ {
+		if err := b.PreCreate(context.Background(), 1); err != nil {
+			t.Fatalf("Error pre create: %v", err)
+		}
+	}
+	// 当前及下一个周期，重复调用不重复建表
+	if got := s.executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// 按登记的repo预建，未开启自动建表的跳过
+	other := &fakeDB{}
+	NewBaseRepo(newFakeDB(t, other), WithTableName("test_precreate_manual"), WithShard(true, ShardTypeDay))
+	latest := &fakeDB{}
+	NewBaseRepo(newFakeDB(t, latest), WithTableName("test_precreate"), WithShard(true, ShardTypeMonth), WithAutoCreate())
+	if err := PreCreateShardTables(context.Background(), 1); err != nil {
+		t.Fatalf("Error pre create shard tables: %v", err)
+	}
+	if got := latest.executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := other.executed(); len(got) != 0 {
+		t.Errorf("repo without auto create should be skipped, got %q", got)
+	}
+}
@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// instanceSpan 当前语句的span，存于gorm的InstanceSet
+const instanceSpan = "repo:span"
+
+// TracingPlugin 每条SQL创建一个OpenTelemetry的span，父span取自WithContext的ctx；
+// 未开启链路追踪时全局TracerProvider为空实现
+type TracingPlugin struct{}
+
+func (TracingPlugin) Name() string {
+	return "repo:tracing"
+}
+
+func (TracingPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	// 在其他回调之前开始、之后结束，审计等回调执行的查询也计入
+	if err := cb.Create().Before("*").Register("repo:trace_before", startSpan("create")); err != nil {
+		return err
+	}
+	if err := cb.Create().After("*").Register("repo:trace_after", endSpan); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("*").Register("repo:trace_before", startSpan("query")); err != nil {
+		return err
+	}
+	if err := cb.Query().After("*").Register("repo:trace_after", endSpan); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("*").Register("repo:trace_before", startSpan("update")); err != nil {
+		return err
+	}
+	if err := cb.Update().After("*").Register("repo:trace_after", endSpan); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("*").Register("repo:trace_before", startSpan("delete")); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("*").Register("repo:trace_after", endSpan); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("*").Register("repo:trace_before", startSpan("row")); err != nil {
+		return err
+	}
+	if err := cb.Row().After("*").Register("repo:trace_after", endSpan); err != nil {
+		return err
+	}
+	if err := cb.Raw().Before("*").Register("repo:trace_before", startSpan("raw")); err != nil {
+		return err
+	}
+	return cb.Raw().After("*").Register("repo:trace_after", endSpan)
+}
+
+func startSpan(operation string) func(db *gorm.DB) {
+	tracer := otel.Tracer("api-gin/repo")
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		ctx, span := tracer.Start(db.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "mysql"),
+				attribute.String("db.operation", operation),
+			))
+		db.Statement.Context = ctx
+		db.InstanceSet(instanceSpan, span)
+	}
+}
+
+func endSpan(db *gorm.DB) {
+	v, ok := db.InstanceGet(instanceSpan)
+	if !ok {
+		return
+	}
+	span := v.(trace.Span)
+	defer span.End()
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -0,0 +1,176 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newTransTest 以DryRun的DB作为外层事务，记录执行的SQL
+func newTransTest(t *testing.T) (*BaseRepo, context.Context, *sqlRecorder) {
+	db := newDryRunDB(t)
+	rec := recordSQL(db)
+	b := NewBaseRepo(db, WithTableName("hello_world"))
+	return b, b.StartTrans(context.Background(), db), rec
+}
+
+func insert(b *BaseRepo, ctx context.Context, name string) error {
+	db, err := b.Query(ctx, ModeWrite)
+	if err != nil {
+		return err
+	}
+	return db.Create(map[string]any{"name": name}).Error
+}
+
+func TestNestedTransCommit(t *testing.T) {
+	b, ctx, rec := newTransTest(t)
+	err := b.Transaction(ctx, func(ctx context.Context) error {
+		if err := insert(b, ctx, "a"); err != nil {
+			return err
+		}
+		return b.Transaction(ctx, func(ctx context.Context) error {
+			return insert(b, ctx, "b")
+		})
+	})
+	if err != nil {
+		t.Fatalf("Error nested transaction: %v", err)
+	}
+	want := []string{
+		"SAVEPOINT sp_1",
+		"INSERT INTO `hello_world` (`name`) VALUES ('a')",
+		"SAVEPOINT sp_2",
+		"INSERT INTO `hello_world` (`name`) VALUES ('b')",
+		"RELEASE SAVEPOINT sp_2",
+		"RELEASE SAVEPOINT sp_1",
+	}
+	if !reflect.DeepEqual(rec.all, want) {
+		t.Errorf("got %q, want %q", rec.all, want)
+	}
+}
+
+func TestNestedTransRollback(t *testing.T) {
+	b, ctx, rec := newTransTest(t)
+	errInner := errors.New("inner failed")
+	err := b.Transaction(ctx, func(ctx context.Context) error {
+		if err := insert(b, ctx, "a"); err != nil {
+			return err
+		}
+		// 内层失败只回滚到内层保存点，外层继续
+		if err := b.Transaction(ctx, func(ctx context.Context) error {
+			if err := insert(b, ctx, "b"); err != nil {
+				return err
+			}
+			return errInner
+		}); !errors.Is(err, errInner) {
+			t.Errorf("expect inner error, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error nested transaction: %v", err)
+	}
+	want := []string{
+		"SAVEPOINT sp_1",
+		"INSERT INTO `hello_world` (`name`) VALUES ('a')",
+		"SAVEPOINT sp_2",
+		"INSERT INTO `hello_world` (`name`) VALUES ('b')",
+		"ROLLBACK TO SAVEPOINT sp_2",
+		"RELEASE SAVEPOINT sp_1",
+	}
+	if !reflect.DeepEqual(rec.all, want) {
+		t.Errorf("got %q, want %q", rec.all, want)
+	}
+}
+
+func TestNestedTransSavePointFailed(t *testing.T) {
+	b, ctx, rec := newTransTest(t)
+	errSavePoint := errors.New("savepoint failed")
+	err := b.Db.Callback().Raw().Before("gorm:raw").Register("test:savepoint", func(db *gorm.DB) {
+		if strings.HasPrefix(db.Statement.SQL.String(), "SAVEPOINT") {
+			_ = db.AddError(errSavePoint)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := false
+	err = b.Transaction(ctx, func(ctx context.Context) error {
+		called = true
+		return insert(b, ctx, "a")
+	})
+	if !errors.Is(err, errSavePoint) {
+		t.Fatalf("expect savepoint error, got %v", err)
+	}
+	if called {
+		t.Errorf("fn should not run without savepoint")
+	}
+	for _, sql := range rec.all {
+		if strings.HasPrefix(sql, "INSERT") {
+			t.Errorf("unexpected sql: %s", sql)
+		}
+	}
+}
+
+func TestTransCommit(t *testing.T) {
+	s := &fakeDB{}
+	b := NewBaseRepo(newFakeDB(t, s), WithTableName("hello_world"))
+	err := b.Transaction(context.Background(), func(ctx context.Context) error {
+		if _, ok := getTransDb(ctx); !ok {
+			t.Errorf("fn should run in transaction")
+		}
+		return insert(b, ctx, "a")
+	})
+	if err != nil {
+		t.Fatalf("Error transaction: %v", err)
+	}
+	want := []string{"BEGIN", "INSERT INTO `hello_world` (`name`) VALUES (?)", "COMMIT"}
+	if got := s.executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTransRollback(t *testing.T) {
+	s := &fakeDB{}
+	b := NewBaseRepo(newFakeDB(t, s), WithTableName("hello_world"))
+	errFn := errors.New("fn failed")
+	err := b.Transaction(context.Background(), func(ctx context.Context) error {
+		if err := insert(b, ctx, "a"); err != nil {
+			return err
+		}
+		return errFn
+	})
+	if !errors.Is(err, errFn) {
+		t.Fatalf("expect fn error, got %v", err)
+	}
+	want := []string{"BEGIN", "INSERT INTO `hello_world` (`name`) VALUES (?)", "ROLLBACK"}
+	if got := s.executed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTransPanic(t *testing.T) {
+	s := &fakeDB{}
+	b := NewBaseRepo(newFakeDB(t, s), WithTableName("hello_world"))
+	defer func() {
+		// 回滚后panic继续向上抛出
+		if r := recover(); r != "boom" {
+			t.Errorf("expect panic boom, got %v", r)
+		}
+		want := []string{"BEGIN", "INSERT INTO `hello_world` (`name`) VALUES (?)", "ROLLBACK"}
+		if got := s.executed(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}()
+	_ = b.Transaction(context.Background(), func(ctx context.Context) error {
+		if err := insert(b, ctx, "a"); err != nil {
+			return err
+		}
+		panic("boom")
+	})
+	t.Errorf("panic should be re-raised")
+}
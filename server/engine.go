@@ -2,39 +2,80 @@ package server
 
 import (
 	"api-gin/config"
+	"api-gin/infra/health"
+	"api-gin/infra/log"
+	"api-gin/infra/metrics"
+	"api-gin/infra/redis"
+	"api-gin/infra/telemetry"
+	"api-gin/middleware"
+	"api-gin/repo"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
 	"time"
 )
 
 type App struct {
 	Host        string
 	Port        int
-	Engine      *gin.Engine  // 引擎
-	Controllers *Controllers // router配置
+	Engine      *gin.Engine    // 引擎
+	Controllers *Controllers   // router配置
+	Admin       *http.Server   // 管理端口，metrics.addr 不为空时创建
+	Health      *health.Health // 就绪检查，关闭时调用Shutdown
 }
 
-func NewApp(config *config.Config, controllers *Controllers) (*App, error) {
+// NewApp tracer由wire创建，开启后gin请求、SQL、redis命令均记录span；m开启后为db、clusters、rdb注册指标；
+// h检查db、clusters的每个主从实例及rdb
+func NewApp(
+	config *config.Config,
+	controllers *Controllers,
+	logger *log.Logger,
+	tracer *telemetry.Provider,
+	m *metrics.Metrics,
+	db *gorm.DB,
+	clusters *repo.Clusters,
+	rdb *redis.RedisClient,
+	h *health.Health,
+) (*App, error) {
 	if config == nil {
 		return nil, fmt.Errorf("[App] 配置不能为空")
 	}
+	if logger == nil {
+		return nil, fmt.Errorf("[App] 日志不能为空")
+	}
+	if tracer == nil {
+		return nil, fmt.Errorf("[App] 链路追踪不能为空")
+	}
 	gin.SetMode(config.Mode)
 
 	g := gin.New()
+	// gin.Context.Value 回退到 Request.Context()，repo 等从ctx中读取的值才能生效
+	g.ContextWithFallback = true
 
 	loc, _ := time.LoadLocation("Asia/Shanghai")
 	time.Local = loc
 
 	// 引入一些中间件
-	// g.Use(middleware.RecoveryMiddlerware())
-	// g.Use(middleware.LoggerMiddlerware())
+	g.Use(middleware.RequestIdMiddleware())
+	if m.Enabled() {
+		g.Use(middleware.MetricsMiddleware(m))
+	}
+	g.Use(middleware.LoggerMiddlerware(logger))
+	g.Use(middleware.RecoveryMiddlerware(logger))
+	g.Use(middleware.ReadYourWritesMiddleware())
 
 	app := &App{
 		Host:        config.Host,
 		Port:        config.Port,
 		Engine:      g,
 		Controllers: controllers,
+		Health:      h,
+	}
+	if err := app.initMetrics(m, db, clusters, rdb); err != nil {
+		return nil, err
 	}
+	app.initHealth(db, clusters, rdb)
 	app.initRouter()
 
 	return app, nil
@@ -44,6 +85,7 @@ func (a *App) initRouter() {
 	rGroup := a.Engine.RouterGroup
 	api := rGroup.Group("/v1/api/hello")
 	{
+		api.GET("", a.Controllers.HelloController.List)
 		api.GET("/:name", a.Controllers.HelloController.Hello)
 	}
 }
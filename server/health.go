@@ -0,0 +1,43 @@
+package server
+
+import (
+	"api-gin/infra/health"
+	"api-gin/infra/redis"
+	"api-gin/repo"
+	"context"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+// initHealth 注册每个主从实例（含各分库，名称为 分库名/实例名）、redis的就绪检查，以及/healthz、/readyz
+func (a *App) initHealth(db *gorm.DB, clusters *repo.Clusters, rdb *redis.RedisClient) {
+	for _, n := range repo.Nodes(db) {
+		a.Health.Register(n.Name, n.DB.PingContext)
+	}
+	if clusters != nil {
+		for _, name := range clusters.Names() {
+			cdb, _ := clusters.Get(name)
+			for _, n := range repo.Nodes(cdb) {
+				a.Health.Register(name+"/"+n.Name, n.DB.PingContext)
+			}
+		}
+	}
+	if rdb != nil {
+		a.Health.Register("redis", func(ctx context.Context) error {
+			return rdb.Client.WithContext(ctx).Ping().Err()
+		})
+	}
+
+	a.Engine.GET("/healthz", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+	})
+	a.Engine.GET("/readyz", func(c *gin.Context) {
+		result, ok := a.Health.Ready(c.Request.Context())
+		status := http.StatusOK
+		if !ok {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, result)
+	})
+}
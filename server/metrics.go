@@ -0,0 +1,38 @@
+package server
+
+import (
+	"api-gin/infra/metrics"
+	"api-gin/infra/redis"
+	"api-gin/repo"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+// initMetrics 为DB、各分库、redis注册指标，并在Engine或单独的管理端口上暴露
+func (a *App) initMetrics(m *metrics.Metrics, db *gorm.DB, clusters *repo.Clusters, rdb *redis.RedisClient) error {
+	if !m.Enabled() {
+		return nil
+	}
+	if err := repo.InstrumentDB(db, m); err != nil {
+		return err
+	}
+	if err := repo.InstrumentClusters(clusters, m); err != nil {
+		return err
+	}
+	if rdb != nil {
+		rdb.Instrument(m)
+	}
+
+	if m.Addr == "" {
+		a.Engine.GET(m.Path, gin.WrapH(m.Handler()))
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle(m.Path, m.Handler())
+	a.Admin = &http.Server{
+		Addr:    m.Addr,
+		Handler: mux,
+	}
+	return nil
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/google/wire"
 )
 
-func Initialize() (*App, error) {
+func Initialize() (*App, func(), error) {
 	panic(wire.Build(
 		baseSet,
 		repoSet,
@@ -4,7 +4,11 @@ import (
 	"api-gin/config"
 	"api-gin/controller"
 	"api-gin/handler"
+	"api-gin/infra/health"
 	"api-gin/infra/log"
+	"api-gin/infra/metrics"
+	"api-gin/infra/redis"
+	"api-gin/infra/telemetry"
 	"api-gin/repo"
 	"github.com/google/wire"
 )
@@ -15,10 +19,18 @@ var (
 		config.GetMySQLConfig,
 		config.GetLogConfig,
 		config.GetRedisConfig,
+		config.GetTelemetryConfig,
+		config.GetMetricsConfig,
+		config.GetHealthConfig,
 		log.NewLogger,
+		telemetry.NewProvider,
+		metrics.NewMetrics,
+		health.NewHealth,
+		redis.NewOptionalClient,
 	)
 	repoSet = wire.NewSet(
-		repo.NewDB,
+		repo.OpenDB,
+		repo.NewClusters,
 		repo.NewUserRepo,
 	)
 	// serviceSet = wire.NewSet()